@@ -0,0 +1,297 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// A long running daemon that owns the HT16K33 displays, so that any
+// number of scripts can share them without each opening the I2C bus.
+// Clients print, scroll, clear, set the brightness and show matrix
+// frames through a local HTTP/JSON API, described in http.go, or a
+// line protocol on a Unix socket, described in socket.go. It can also
+// subscribe to MQTT topics and show what is published on them, as set
+// up in mqtt.go.
+//
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/wbeebe/rpi/devices"
+)
+
+func help() {
+	helpText := []string{
+		"\n Owns the HT16K33 displays and serves them to other programs\n",
+		" Usage: displayd [config file]\n",
+		"  config file - JSON describing the displays, for example:\n",
+		"    {",
+		"      \"listen\": \"127.0.0.1:8016\",",
+		"      \"socket\": \"/tmp/displayd.sock\",",
+		"      \"displays\": [",
+		"        { \"name\": \"alpha\", \"type\": \"alphanumeric\", \"addresses\": [\"0x70\", \"0x71\"] },",
+		"        { \"name\": \"matrix\", \"type\": \"matrix816\", \"addresses\": [\"0x72\"] }",
+		"      ],",
+		"      \"mqtt\": {",
+		"        \"broker\": \"tcp://lab.local:1883\",",
+		"        \"subscriptions\": [",
+		"          { \"topic\": \"lab/status\", \"display\": \"alpha\", \"action\": \"scroll\" }",
+		"        ]",
+		"      }",
+		"    }\n",
+		"  Display types are alphanumeric, sevensegment, matrix816, matrix88 and bicolor88.",
+		"  Two alphanumeric displays can be chained, the second on the left.",
+		"  An empty socket turns the Unix socket off.",
+		"  MQTT is only used when a broker is given. Each subscription shows the",
+		"  payloads on a topic with print, scroll, clear, brightness or frame, and",
+		"  \"ignore_retained\": true skips retained messages.",
+		"  Set \"simulate\": true to run without any hardware.",
+		"  Without a config file the alphanumeric display at 0x70 is served as",
+		"  \"alpha\", with a second at 0x71 if there is one.\n",
+		" Examples:",
+		" displayd /etc/displayd.json &",
+		" curl -d '{\"text\": \"Hello\"}' http://127.0.0.1:8016/displays/alpha/print",
+		" echo \"PRINT Hello\" | nc -U /tmp/displayd.sock\n",
+	}
+
+	for _, line := range helpText {
+		fmt.Println(line)
+	}
+}
+
+// DefaultListen is where the HTTP API listens unless configured.
+// It is only reachable from the Raspberry Pi itself.
+//
+const DefaultListen = "127.0.0.1:8016"
+
+// DefaultAddress is the I2C address of the default alphanumeric display.
+//
+const DefaultAddress int = 0x70
+
+type displayConfig struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Addresses []string `json:"addresses"`
+}
+
+type config struct {
+	Listen   string          `json:"listen"`
+	Socket   string          `json:"socket"`
+	Simulate bool            `json:"simulate"`
+	Displays []displayConfig `json:"displays"`
+	MQTT     mqttConfig      `json:"mqtt"`
+}
+
+func loadConfig(path string) (config, error) {
+	conf := config{Listen: DefaultListen, Socket: devices.DISPLAYD_SOCKET}
+
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return conf, err
+	}
+	if err := json.Unmarshal(text, &conf); err != nil {
+		return conf, fmt.Errorf(" %s: %v", path, err)
+	}
+	return conf, nil
+}
+
+// daemon holds the displays by name. names keeps them in the order
+// they were configured; the first is the default display.
+//
+type daemon struct {
+	displays map[string]*managedDisplay
+	names    []string
+	socket   net.Listener
+	mqtt     mqtt.Client
+}
+
+// display returns a display by name, or the default display for "".
+//
+func (d *daemon) display(name string) (*managedDisplay, error) {
+	if len(name) == 0 && len(d.names) > 0 {
+		name = d.names[0]
+	}
+	if display, ok := d.displays[name]; ok {
+		return display, nil
+	}
+	return nil, fmt.Errorf("no display named %q", name)
+}
+
+// Close disconnects from MQTT, stops the Unix socket, which removes
+// it, and clears and closes every display once the action under way
+// on it is done.
+//
+func (d *daemon) Close() {
+	if d.mqtt != nil {
+		d.mqtt.Disconnect(250)
+	}
+	if d.socket != nil {
+		d.socket.Close()
+	}
+	for _, display := range d.displays {
+		display.Close()
+	}
+}
+
+// startDriver starts an HT16K33, or a simulated one.
+//
+func startDriver(address int, simulate bool) (*devices.HT16K33Driver, error) {
+	driver := devices.NewHT16K33Driver(address)
+	if simulate {
+		return driver, driver.StartWithConnection(devices.NewSimulatedConnection())
+	}
+	return driver, driver.Start()
+}
+
+func newDaemon(conf config) (*daemon, error) {
+	d := &daemon{displays: map[string]*managedDisplay{}}
+
+	for _, dc := range conf.Displays {
+		if _, ok := d.displays[dc.Name]; ok || len(dc.Name) == 0 {
+			d.Close()
+			return nil, fmt.Errorf(" Display names must be unique and not empty: %q", dc.Name)
+		}
+		if len(dc.Addresses) == 0 {
+			d.Close()
+			return nil, fmt.Errorf(" Display %s has no addresses", dc.Name)
+		}
+
+		var drivers []*devices.HT16K33Driver
+		for _, text := range dc.Addresses {
+			address, err := strconv.ParseInt(text, 0, 32)
+			if err == nil {
+				var driver *devices.HT16K33Driver
+				if driver, err = startDriver(int(address), conf.Simulate); err == nil {
+					drivers = append(drivers, driver)
+				}
+			}
+			if err != nil {
+				d.Close()
+				return nil, err
+			}
+		}
+
+		display, err := newManagedDisplay(dc.Name, dc.Type, drivers)
+		if err != nil {
+			d.Close()
+			return nil, err
+		}
+		d.displays[dc.Name] = display
+		d.names = append(d.names, dc.Name)
+	}
+
+	return d, nil
+}
+
+// defaultConfig serves the alphanumeric display at 0x70 and, as in
+// rpi display, chains a second at 0x71 when it answers.
+//
+func defaultConfig() config {
+	addresses := []string{fmt.Sprintf("0x%x", DefaultAddress)}
+
+	second := devices.NewHT16K33Driver(DefaultAddress + 1)
+	if err := second.Start(); err == nil {
+		second.Close()
+		addresses = append(addresses, fmt.Sprintf("0x%x", DefaultAddress+1))
+	}
+
+	return config{
+		Listen:   DefaultListen,
+		Socket:   devices.DISPLAYD_SOCKET,
+		Displays: []displayConfig{{Name: "alpha", Type: "alphanumeric", Addresses: addresses}},
+	}
+}
+
+func main() {
+	var conf config
+
+	if len(os.Args) > 1 {
+		if os.Args[1] == "-h" {
+			help()
+			return
+		}
+
+		var err error
+		if conf, err = loadConfig(os.Args[1]); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		conf = defaultConfig()
+	}
+
+	d, err := newDaemon(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Hook the various system abort calls for us to use or ignore as we
+	// see fit. In particular hook SIGINT and SIGTERM, to stop the daemon.
+	//
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan,
+		syscall.SIGHUP,
+		syscall.SIGINT,
+		syscall.SIGTERM,
+		syscall.SIGQUIT)
+
+	// We want to clear the displays before we exit. We don't want to
+	// leave them lit when the daemon is stopped.
+	//
+	go func() {
+		for {
+			signal := <-signalChan
+			switch signal {
+			case syscall.SIGINT, syscall.SIGTERM:
+				fmt.Println()
+				d.Close()
+				os.Exit(0)
+			default:
+			}
+		}
+	}()
+
+	if len(conf.Socket) > 0 {
+		if d.socket, err = listenSocket(conf.Socket); err != nil {
+			d.Close()
+			log.Fatal(err)
+		}
+		fmt.Printf(" Listening on %s\n", conf.Socket)
+		go d.serveSocket(d.socket)
+	}
+
+	if len(conf.MQTT.Broker) > 0 {
+		if err := d.startMQTT(conf.MQTT); err != nil {
+			d.Close()
+			log.Fatal(err)
+		}
+	}
+
+	listener, err := net.Listen("tcp", conf.Listen)
+	if err != nil {
+		d.Close()
+		log.Fatal(err)
+	}
+
+	fmt.Printf(" Serving %d displays on http://%s/displays\n", len(d.names), listener.Addr())
+	log.Fatal(http.Serve(listener, http.HandlerFunc(d.serveHTTP)))
+}
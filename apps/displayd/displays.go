@@ -0,0 +1,313 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wbeebe/rpi/devices"
+)
+
+// The actions every client of the daemon can ask for, whether over
+// HTTP, the Unix socket or MQTT. The argument is the text for print
+// and scroll, a level for brightness, and column bytes in hex for frame.
+//
+const (
+	actionPrint      = "print"
+	actionScroll     = "scroll"
+	actionClear      = "clear"
+	actionBrightness = "brightness"
+	actionFrame      = "frame"
+)
+
+// queueLength is how many actions a display holds while it is busy,
+// typically scrolling, before it turns more away.
+//
+const queueLength = 32
+
+// displayState is what a display is showing, as reported to clients.
+//
+type displayState struct {
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	Width      int       `json:"width"`
+	Pixels     bool      `json:"pixels"`
+	Mode       string    `json:"mode"`
+	Text       string    `json:"text,omitempty"`
+	Frame      string    `json:"frame,omitempty"`
+	Brightness int       `json:"brightness"`
+	Pending    int       `json:"pending"`
+	Updated    time.Time `json:"updated"`
+}
+
+// managedDisplay is one display owned by the daemon: one or more
+// HT16K33s shown as a single TextDisplay, and as a PixelDisplay when
+// it is a matrix.
+//
+// Actions are queued and carried out one at a time by the display's
+// own goroutine, so a long scroll never interleaves with anything else
+// and clients don't wait for it. Close stops the goroutine before it
+// touches the HT16K33s, so the two never write to them at once.
+//
+type managedDisplay struct {
+	drivers []*devices.HT16K33Driver
+	text    devices.TextDisplay
+	pixels  devices.PixelDisplay
+	queue   chan func()
+
+	// sending guards sends on queue against Close closing it.
+	sending   sync.RWMutex
+	closed    bool
+	closing   chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+
+	mutex sync.Mutex
+	state displayState
+}
+
+func newManagedDisplay(name, kind string, drivers []*devices.HT16K33Driver) (*managedDisplay, error) {
+	display := &managedDisplay{
+		drivers: drivers,
+		queue:   make(chan func(), queueLength),
+		closing: make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	switch kind {
+	case "alphanumeric":
+		alpha := devices.NewAdafruit54AlphaDisplay(drivers[0])
+		if len(drivers) > 1 {
+			alpha.SetNeighborDisplay(devices.NewAdafruit54AlphaDisplay(drivers[1]))
+		}
+		display.text = alpha
+	case "sevensegment":
+		display.text = devices.NewAdafruit7SegmentDisplay(drivers[0])
+	case "matrix816":
+		matrix := devices.NewAdafruit816LedMatrix(drivers[0])
+		display.text, display.pixels = matrix, matrix
+	case "matrix88":
+		matrix := devices.NewAdafruit88LedMatrix(drivers[0], devices.MATRIX_88_MINI)
+		display.text, display.pixels = matrix, matrix
+	case "bicolor88":
+		matrix := devices.NewAdafruitBicolor88Matrix(drivers[0])
+		display.text, display.pixels = matrix, matrix
+	default:
+		return nil, fmt.Errorf(" Unknown display type %q", kind)
+	}
+
+	display.state = displayState{
+		Name:       name,
+		Type:       kind,
+		Width:      display.text.Width(),
+		Pixels:     display.pixels != nil,
+		Mode:       actionClear,
+		Brightness: devices.HT16K33_MAX_BRIGHTNESS,
+		Updated:    time.Now(),
+	}
+
+	go display.run()
+	return display, nil
+}
+
+// run carries out queued actions until the queue is closed. Once Close
+// has begun, whatever is still queued is skipped.
+//
+func (d *managedDisplay) run() {
+	defer close(d.stopped)
+
+	for action := range d.queue {
+		select {
+		case <-d.closing:
+		default:
+			action()
+		}
+	}
+}
+
+// send queues an action, unless the display is closed.
+//
+func (d *managedDisplay) send(do func(), wait bool) error {
+	d.sending.RLock()
+	defer d.sending.RUnlock()
+
+	if d.closed {
+		return fmt.Errorf("%s is closed", d.state.Name)
+	}
+	if wait {
+		d.queue <- do
+		return nil
+	}
+
+	select {
+	case d.queue <- do:
+		return nil
+	default:
+		return fmt.Errorf("%s is busy, with %d actions waiting", d.state.Name, queueLength)
+	}
+}
+
+// State returns a copy of what the display is showing.
+//
+func (d *managedDisplay) State() displayState {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	state := d.state
+	state.Pending = len(d.queue)
+	return state
+}
+
+func (d *managedDisplay) update(change func(state *displayState)) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	change(&d.state)
+	d.state.Updated = time.Now()
+}
+
+// Perform checks an action and its argument, then queues it. Errors
+// in the request are returned at once; the action itself happens once
+// everything queued before it is done.
+//
+func (d *managedDisplay) Perform(action, argument string) error {
+	var do func()
+
+	switch action {
+	case actionPrint:
+		do = func() {
+			d.text.Write(argument)
+			d.update(func(s *displayState) { s.Mode, s.Text, s.Frame = actionPrint, argument, "" })
+		}
+	case actionScroll:
+		do = func() {
+			d.update(func(s *displayState) { s.Mode, s.Text, s.Frame = actionScroll, argument, "" })
+			d.text.Scroll(argument)
+			d.update(func(s *displayState) {
+				if s.Mode == actionScroll {
+					s.Mode, s.Text = actionClear, ""
+				}
+			})
+		}
+	case actionClear:
+		do = func() {
+			d.text.Clear()
+			d.update(func(s *displayState) { s.Mode, s.Text, s.Frame = actionClear, "", "" })
+		}
+	case actionBrightness:
+		level, err := strconv.Atoi(strings.TrimSpace(argument))
+		if err != nil || level < 0 || level > devices.HT16K33_MAX_BRIGHTNESS {
+			return fmt.Errorf("brightness needs a level from 0 to %d", devices.HT16K33_MAX_BRIGHTNESS)
+		}
+		do = func() {
+			for _, driver := range d.drivers {
+				driver.SetBrightness(level)
+			}
+			d.update(func(s *displayState) { s.Brightness = level })
+		}
+	case actionFrame:
+		if d.pixels == nil {
+			return fmt.Errorf("%s is not a matrix", d.state.Name)
+		}
+		columns, err := frameColumns(argument, d.pixels.Bounds().Dx())
+		if err != nil {
+			return err
+		}
+		do = func() {
+			showFrame(d.pixels, columns)
+			d.update(func(s *displayState) { s.Mode, s.Text, s.Frame = actionFrame, "", hex.EncodeToString(columns) })
+		}
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+
+	return d.send(do, false)
+}
+
+// Wait returns once every action queued before it is done, or once
+// the display is closed.
+//
+func (d *managedDisplay) Wait() {
+	done := make(chan struct{})
+	if d.send(func() { close(done) }, true) != nil {
+		return
+	}
+
+	select {
+	case <-done:
+	case <-d.stopped:
+	}
+}
+
+// frameColumns decodes a frame given as column bytes in hex, with bit
+// 0x80 at the top and the leftmost column first, as in rpi animate.
+// Spaces between bytes are allowed.
+//
+func frameColumns(value string, width int) ([]byte, error) {
+	columns, err := hex.DecodeString(strings.Join(strings.Fields(value), ""))
+	if err != nil {
+		return nil, fmt.Errorf("bad frame hex %q: %v", value, err)
+	}
+	if len(columns) != width {
+		return nil, fmt.Errorf("frame needs %d column bytes, found %d", width, len(columns))
+	}
+	return columns, nil
+}
+
+func showFrame(pixels devices.PixelDisplay, columns []byte) {
+	for x, bits := range columns {
+		for y := 0; y < pixels.Bounds().Dy(); y++ {
+			c := color.Color(color.Black)
+			if bits&(0x80>>uint(y)) != 0 {
+				c = color.White
+			}
+			pixels.Set(x, y, c)
+		}
+	}
+	pixels.Flush()
+}
+
+// Close stops taking actions, waits for the one under way to finish,
+// then clears the display and closes its HT16K33s. Anything still
+// queued is abandoned.
+//
+func (d *managedDisplay) Close() {
+	d.closeOnce.Do(func() {
+		// Skipping what is queued also frees a Wait blocked on a full
+		// queue, so that it lets go of sending.
+		//
+		close(d.closing)
+
+		d.sending.Lock()
+		d.closed = true
+		close(d.queue)
+		d.sending.Unlock()
+		<-d.stopped
+
+		d.text.Clear()
+		for _, driver := range d.drivers {
+			driver.Clear()
+			driver.Close()
+		}
+	})
+}
@@ -0,0 +1,64 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wbeebe/rpi/devices"
+)
+
+func TestCloseWaitsForScroll(t *testing.T) {
+	sim := devices.NewSimulatedConnection()
+	driver := devices.NewHT16K33Driver(DefaultAddress)
+	if err := driver.StartWithConnection(sim); err != nil {
+		t.Fatal(err)
+	}
+	display, err := newManagedDisplay("alpha", "alphanumeric", []*devices.HT16K33Driver{driver})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := display.Perform(actionScroll, "AB"); err != nil {
+		t.Fatal(err)
+	}
+	if err := display.Perform(actionPrint, "LATE"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	display.Close()
+
+	// The scroll finished before the display was cleared, and what was
+	// queued behind it was dropped.
+	//
+	if state := display.State(); state.Mode != actionClear || state.Text != "" {
+		t.Errorf("display is in mode %s showing %q after closing", state.Mode, state.Text)
+	}
+	for reg := 0; reg < 16; reg++ {
+		if val := sim.Register(uint8(reg)); val != 0 {
+			t.Fatalf("display RAM %02x is %02x after closing", reg, val)
+		}
+	}
+
+	if err := display.Perform(actionPrint, "MORE"); err == nil {
+		t.Error("a closed display took an action")
+	}
+	display.Wait()
+	display.Close()
+}
@@ -0,0 +1,123 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The HTTP API.
+//
+//	GET  /displays                  - The state of every display.
+//	GET  /displays/<name>           - The state of one display.
+//	POST /displays/<name>/<action>  - Queues an action, returning the state.
+//
+// Action bodies are JSON:
+//
+//	print      {"text": "Hello"}
+//	scroll     {"text": "The quick brown fox"}
+//	clear      {}
+//	brightness {"level": 8}
+//	frame      {"hex": "3c 42 a9 85 85 a9 42 3c"}
+//
+// Errors are returned as {"error": "..."} with a 4xx status.
+//
+
+// actionRequest is the body of a POST.
+//
+type actionRequest struct {
+	Text  string `json:"text"`
+	Level *int   `json:"level"`
+	Hex   string `json:"hex"`
+}
+
+// argument returns the part of the request an action uses.
+//
+func (r actionRequest) argument(action string) string {
+	switch action {
+	case actionBrightness:
+		if r.Level == nil {
+			return ""
+		}
+		return strconv.Itoa(*r.Level)
+	case actionFrame:
+		return r.Hex
+	}
+	return r.Text
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": strings.TrimSpace(err.Error())})
+}
+
+func (d *daemon) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/displays" && !strings.HasPrefix(r.URL.Path, "/displays/") {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such path %s", r.URL.Path))
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/displays"), "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		var states []displayState
+		for _, name := range d.names {
+			states = append(states, d.displays[name].State())
+		}
+		writeJSON(w, http.StatusOK, states)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		display, err := d.display(parts[0])
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, display.State())
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		display, err := d.display(parts[0])
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+
+		var request actionRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		if err := display.Perform(parts[1], request.argument(parts[1])); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, display.State())
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s is not allowed on %s", r.Method, r.URL.Path))
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// The optional MQTT subscriber. Each subscription maps the messages on
+// a topic, which may have wildcards, to an action on a named display,
+// with the payload as the argument: the text for print and scroll, a
+// level for brightness, and hex column bytes for frame. For example:
+//
+//	"mqtt": {
+//	  "broker": "tcp://lab.local:1883",
+//	  "subscriptions": [
+//	    { "topic": "lab/status", "display": "alpha", "action": "scroll" },
+//	    { "topic": "lab/frames/#", "display": "matrix", "action": "frame" }
+//	  ]
+//	}
+//
+// Retained messages are the current value of a topic, so by default
+// they are shown like any other. As the broker sends them again after
+// every reconnect, a retained message is skipped when it repeats what
+// the subscription last showed for its topic. Set "ignore_retained" to skip them all.
+//
+
+// mqttSubscription maps one topic onto a display.
+//
+type mqttSubscription struct {
+	Topic          string `json:"topic"`
+	Display        string `json:"display"`
+	Action         string `json:"action"`
+	QoS            byte   `json:"qos"`
+	IgnoreRetained bool   `json:"ignore_retained"`
+}
+
+type mqttConfig struct {
+	Broker        string             `json:"broker"`
+	ClientID      string             `json:"client_id"`
+	Username      string             `json:"username"`
+	Password      string             `json:"password"`
+	Subscriptions []mqttSubscription `json:"subscriptions"`
+}
+
+// The client ID used unless one is configured, and how long to wait
+// between attempts to reach a broker that is down, which doubles up to
+// mqttMaxRetry.
+//
+const (
+	mqttClientID   = "displayd"
+	mqttFirstRetry = time.Second
+	mqttMaxRetry   = time.Minute
+)
+
+// subscriber routes the messages for one subscription to its display.
+// last holds the payload last seen on each topic, as a wildcard
+// subscription has many.
+//
+type subscriber struct {
+	mqttSubscription
+	display *managedDisplay
+
+	mutex sync.Mutex
+	last  map[string]string
+}
+
+func (s *subscriber) handle(client mqtt.Client, message mqtt.Message) {
+	payload := strings.TrimSpace(string(message.Payload()))
+
+	s.mutex.Lock()
+	last, seen := s.last[message.Topic()]
+	repeat := message.Retained() && seen && payload == last
+	s.last[message.Topic()] = payload
+	s.mutex.Unlock()
+
+	if message.Retained() && (s.IgnoreRetained || repeat) {
+		return
+	}
+
+	if err := s.display.Perform(s.Action, payload); err != nil {
+		log.Printf(" %s: %v", message.Topic(), err)
+	}
+}
+
+// newSubscribers checks every subscription against the displays.
+//
+func (d *daemon) newSubscribers(subscriptions []mqttSubscription) ([]*subscriber, error) {
+	var subscribers []*subscriber
+
+	for _, subscription := range subscriptions {
+		display, err := d.display(subscription.Display)
+		if err != nil {
+			return nil, fmt.Errorf(" MQTT topic %s: %v", subscription.Topic, err)
+		}
+		if len(subscription.Topic) == 0 {
+			return nil, fmt.Errorf(" An MQTT subscription has no topic")
+		}
+		if subscription.QoS > 2 {
+			return nil, fmt.Errorf(" MQTT topic %s: QoS %d is out of range 0-2", subscription.Topic, subscription.QoS)
+		}
+
+		switch subscription.Action {
+		case "":
+			subscription.Action = actionScroll
+		case actionPrint, actionScroll, actionClear, actionBrightness:
+		case actionFrame:
+			if display.pixels == nil {
+				return nil, fmt.Errorf(" MQTT topic %s: %s is not a matrix", subscription.Topic, display.State().Name)
+			}
+		default:
+			return nil, fmt.Errorf(" MQTT topic %s: unknown action %q", subscription.Topic, subscription.Action)
+		}
+
+		subscribers = append(subscribers, &subscriber{
+			mqttSubscription: subscription,
+			display:          display,
+			last:             map[string]string{},
+		})
+	}
+
+	return subscribers, nil
+}
+
+// startMQTT connects to the broker in the background, retrying until
+// it answers. Once connected, paho reconnects by itself, and every
+// topic is subscribed to again each time it does.
+//
+func (d *daemon) startMQTT(conf mqttConfig) error {
+	subscribers, err := d.newSubscribers(conf.Subscriptions)
+	if err != nil {
+		return err
+	}
+
+	clientID := conf.ClientID
+	if len(clientID) == 0 {
+		clientID = mqttClientID
+	}
+
+	options := mqtt.NewClientOptions()
+	options.AddBroker(conf.Broker)
+	options.SetClientID(clientID)
+	options.SetUsername(conf.Username)
+	options.SetPassword(conf.Password)
+	options.SetAutoReconnect(true)
+	options.SetMaxReconnectInterval(mqttMaxRetry)
+
+	options.SetOnConnectHandler(func(client mqtt.Client) {
+		fmt.Printf(" Connected to MQTT broker %s\n", conf.Broker)
+		for _, s := range subscribers {
+			token := client.Subscribe(s.Topic, s.QoS, s.handle)
+			if token.Wait() && token.Error() != nil {
+				log.Printf(" MQTT topic %s: %v", s.Topic, token.Error())
+			}
+		}
+	})
+	options.SetConnectionLostHandler(func(client mqtt.Client, err error) {
+		log.Printf(" Lost MQTT broker %s, reconnecting: %v", conf.Broker, err)
+	})
+
+	d.mqtt = mqtt.NewClient(options)
+	go connectMQTT(d.mqtt, conf.Broker)
+	return nil
+}
+
+// connectMQTT makes the first connection, which paho won't retry.
+//
+func connectMQTT(client mqtt.Client, broker string) {
+	retry := mqttFirstRetry
+	for {
+		token := client.Connect()
+		if token.Wait() && token.Error() == nil {
+			return
+		}
+
+		log.Printf(" Can't reach MQTT broker %s, retrying in %v: %v", broker, retry, token.Error())
+		time.Sleep(retry)
+		if retry *= 2; retry > mqttMaxRetry {
+			retry = mqttMaxRetry
+		}
+	}
+}
@@ -0,0 +1,355 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testBroker is just enough of an MQTT 3.1.1 broker for the subscriber:
+// CONNECT, SUBSCRIBE with retained messages, QoS 0 PUBLISH, PINGREQ
+// and DISCONNECT. Wildcards are limited to a trailing /#.
+//
+type testBroker struct {
+	listener net.Listener
+
+	mutex         sync.Mutex
+	connections   map[net.Conn][]string
+	retained      map[string]string
+	subscriptions int
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &testBroker{
+		listener:    listener,
+		connections: map[net.Conn][]string{},
+		retained:    map[string]string{},
+	}
+	go b.accept()
+	return b
+}
+
+func (b *testBroker) URL() string { return "tcp://" + b.listener.Addr().String() }
+
+func (b *testBroker) Close() {
+	b.listener.Close()
+	b.Drop()
+}
+
+// Drop closes every client connection, as a broker restart would.
+//
+func (b *testBroker) Drop() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for connection := range b.connections {
+		connection.Close()
+	}
+}
+
+// Subscriptions counts the SUBSCRIBE packets received.
+//
+func (b *testBroker) Subscriptions() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.subscriptions
+}
+
+// Publish stores a retained message, then sends it to every matching
+// subscription.
+//
+func (b *testBroker) Publish(topic, payload string, retain bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if retain {
+		b.retained[topic] = payload
+	}
+	for connection, filters := range b.connections {
+		for _, filter := range filters {
+			if topicMatches(filter, topic) {
+				writePublish(connection, topic, payload, false)
+			}
+		}
+	}
+}
+
+func topicMatches(filter, topic string) bool {
+	if strings.HasSuffix(filter, "/#") {
+		return strings.HasPrefix(topic, filter[:len(filter)-1])
+	}
+	return filter == topic
+}
+
+func encodeLength(n int) []byte {
+	var out []byte
+	for {
+		digit := byte(n % 128)
+		if n /= 128; n > 0 {
+			digit |= 0x80
+		}
+		out = append(out, digit)
+		if n == 0 {
+			return out
+		}
+	}
+}
+
+func readLength(r *bufio.Reader) (int, error) {
+	n, multiplier := 0, 1
+	for {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n += int(digit&0x7F) * multiplier
+		if digit&0x80 == 0 {
+			return n, nil
+		}
+		multiplier *= 128
+	}
+}
+
+func writePublish(connection net.Conn, topic, payload string, retain bool) {
+	body := append([]byte{byte(len(topic) >> 8), byte(len(topic))}, topic...)
+	body = append(body, payload...)
+
+	header := byte(0x30)
+	if retain {
+		header |= 0x01
+	}
+	connection.Write(append(append([]byte{header}, encodeLength(len(body))...), body...))
+}
+
+func (b *testBroker) accept() {
+	for {
+		connection, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		b.mutex.Lock()
+		b.connections[connection] = nil
+		b.mutex.Unlock()
+
+		go b.serve(connection)
+	}
+}
+
+func (b *testBroker) serve(connection net.Conn) {
+	defer func() {
+		b.mutex.Lock()
+		delete(b.connections, connection)
+		b.mutex.Unlock()
+		connection.Close()
+	}()
+
+	r := bufio.NewReader(connection)
+	for {
+		header, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		length, err := readLength(r)
+		if err != nil {
+			return
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case 1: // CONNECT
+			connection.Write([]byte{0x20, 2, 0, 0})
+		case 8: // SUBSCRIBE, one topic per packet as paho sends them
+			n := int(body[2])<<8 | int(body[3])
+			filter := string(body[4 : 4+n])
+
+			b.mutex.Lock()
+			b.subscriptions++
+			b.connections[connection] = append(b.connections[connection], filter)
+			connection.Write([]byte{0x90, 3, body[0], body[1], 0})
+			for topic, payload := range b.retained {
+				if topicMatches(filter, topic) {
+					writePublish(connection, topic, payload, true)
+				}
+			}
+			b.mutex.Unlock()
+		case 12: // PINGREQ
+			connection.Write([]byte{0xD0, 0})
+		case 14: // DISCONNECT
+			return
+		}
+	}
+}
+
+// newTestDaemon serves two simulated alphanumeric displays, alpha and
+// marker, with MQTT from the broker.
+//
+func newTestDaemon(t *testing.T, broker *testBroker, subscriptions []mqttSubscription) *daemon {
+	d, err := newDaemon(config{
+		Simulate: true,
+		Displays: []displayConfig{
+			{Name: "alpha", Type: "alphanumeric", Addresses: []string{"0x70"}},
+			{Name: "marker", Type: "alphanumeric", Addresses: []string{"0x71"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := mqttConfig{Broker: broker.URL(), ClientID: t.Name(), Subscriptions: subscriptions}
+	if err := d.startMQTT(conf); err != nil {
+		d.Close()
+		t.Fatal(err)
+	}
+	return d
+}
+
+// waitFor polls until condition holds, failing the test after a few
+// seconds, which covers paho's first reconnect delay.
+//
+func waitFor(t *testing.T, what string, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func waitForText(t *testing.T, display *managedDisplay, text string) {
+	waitFor(t, "text "+text, func() bool { return display.State().Text == text })
+}
+
+func TestMQTTRoutesToDisplays(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.Close()
+
+	d := newTestDaemon(t, broker, []mqttSubscription{
+		{Topic: "lab/status", Display: "alpha", Action: actionPrint},
+		{Topic: "lab/marker", Display: "marker", Action: actionPrint},
+		{Topic: "lab/bright", Display: "alpha", Action: actionBrightness},
+	})
+	defer d.Close()
+
+	waitFor(t, "subscriptions", func() bool { return broker.Subscriptions() == 3 })
+	alpha, _ := d.display("alpha")
+	marker, _ := d.display("marker")
+
+	broker.Publish("lab/status", " OK ", false)
+	waitForText(t, alpha, "OK")
+
+	broker.Publish("lab/marker", "MARK", false)
+	waitForText(t, marker, "MARK")
+	if text := alpha.State().Text; text != "OK" {
+		t.Errorf("alpha shows %q after a message for marker", text)
+	}
+
+	broker.Publish("lab/bright", "3", false)
+	waitFor(t, "brightness 3", func() bool { return alpha.State().Brightness == 3 })
+}
+
+func TestMQTTSkipsRetainedRepeatsAfterReconnect(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.Close()
+
+	broker.Publish("lab/frames/a", "AAAA", true)
+	broker.Publish("lab/frames/b", "BBBB", true)
+
+	d := newTestDaemon(t, broker, []mqttSubscription{
+		{Topic: "lab/frames/#", Display: "alpha", Action: actionPrint},
+		{Topic: "lab/marker", Display: "marker", Action: actionPrint},
+	})
+	defer d.Close()
+
+	// Both retained messages are shown on the first connection.
+	//
+	alpha, _ := d.display("alpha")
+	marker, _ := d.display("marker")
+	waitFor(t, "subscriptions", func() bool { return broker.Subscriptions() == 2 })
+	broker.Publish("lab/marker", "ONE", false)
+	waitForText(t, marker, "ONE")
+	alpha.Wait()
+	if text := alpha.State().Text; text != "AAAA" && text != "BBBB" {
+		t.Fatalf("alpha shows %q, not a retained message", text)
+	}
+
+	broker.Publish("lab/frames/c", "CCCC", false)
+	waitForText(t, alpha, "CCCC")
+
+	// After a reconnect every topic is subscribed to again, and the
+	// broker sends both retained messages again. Messages are handled
+	// in order, so once the marker is shown any repeat has reached
+	// alpha's queue.
+	//
+	broker.Drop()
+	waitFor(t, "resubscribe", func() bool { return broker.Subscriptions() == 4 })
+	broker.Publish("lab/marker", "TWO", false)
+	waitForText(t, marker, "TWO")
+	alpha.Wait()
+	if text := alpha.State().Text; text != "CCCC" {
+		t.Errorf("alpha shows %q, a repeated retained message", text)
+	}
+
+	// A retained message that changed while disconnected is shown.
+	//
+	broker.Drop()
+	broker.Publish("lab/frames/a", "NEWA", true)
+	waitFor(t, "resubscribe", func() bool { return broker.Subscriptions() == 6 })
+	waitForText(t, alpha, "NEWA")
+}
+
+func TestMQTTIgnoreRetained(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.Close()
+
+	broker.Publish("lab/status", "OLD", true)
+
+	d := newTestDaemon(t, broker, []mqttSubscription{
+		{Topic: "lab/status", Display: "alpha", Action: actionPrint, IgnoreRetained: true},
+		{Topic: "lab/marker", Display: "marker", Action: actionPrint},
+	})
+	defer d.Close()
+
+	alpha, _ := d.display("alpha")
+	marker, _ := d.display("marker")
+	waitFor(t, "subscriptions", func() bool { return broker.Subscriptions() == 2 })
+	broker.Publish("lab/marker", "MARK", false)
+	waitForText(t, marker, "MARK")
+	alpha.Wait()
+	if text := alpha.State().Text; text != "" {
+		t.Errorf("alpha shows %q, a retained message it should ignore", text)
+	}
+
+	broker.Publish("lab/status", "NEW", false)
+	waitForText(t, alpha, "NEW")
+}
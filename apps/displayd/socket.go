@@ -0,0 +1,150 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The Unix socket protocol, for scripts that would rather not speak
+// HTTP. Each line is a command, answered with a line of OK, OK and a
+// result, or ERR and the reason. Commands aren't case sensitive; blank
+// lines and lines starting with # are ignored.
+//
+//	DISPLAY [name]  - Selects the display the rest of the commands go
+//	                  to, by default the first. Replies OK <name> <width>.
+//	PRINT text      - Writes text on the display.
+//	SCROLL text     - Scrolls text across the display.
+//	CLEAR           - Blanks the display.
+//	BRIGHT level    - Sets the brightness from 0 to 15.
+//	FRAME hex       - Shows column bytes in hex on a matrix.
+//	WAIT            - Replies once everything queued before it is done.
+//	STATE           - Replies OK <state as JSON>.
+//
+// For example:
+//
+//	echo "SCROLL Hello there" | nc -U /tmp/displayd.sock
+//
+// devices.DisplayClient is the Go side of the protocol.
+//
+
+// socketActions maps the protocol's commands onto display actions.
+//
+var socketActions = map[string]string{
+	"PRINT":  actionPrint,
+	"SCROLL": actionScroll,
+	"CLEAR":  actionClear,
+	"BRIGHT": actionBrightness,
+	"FRAME":  actionFrame,
+}
+
+// listenSocket listens on a Unix socket, replacing a socket left
+// behind by a daemon that didn't shut down cleanly. It refuses to take
+// over a socket another daemon is still answering on.
+//
+func listenSocket(path string) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf(" Another displayd is listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// serveSocket accepts connections until the listener is closed.
+//
+func (d *daemon) serveSocket(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go d.serveConnection(conn)
+	}
+}
+
+func (d *daemon) serveConnection(conn net.Conn) {
+	defer conn.Close()
+
+	display, _ := d.display("")
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		command, argument := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			command, argument = line[:i], strings.TrimLeft(line[i:], " \t")
+		}
+
+		reply, err := d.command(&display, strings.ToUpper(command), argument)
+		switch {
+		case err != nil:
+			fmt.Fprintf(conn, "ERR %s\n", strings.TrimSpace(err.Error()))
+		case len(reply) > 0:
+			fmt.Fprintf(conn, "OK %s\n", reply)
+		default:
+			fmt.Fprintln(conn, "OK")
+		}
+	}
+}
+
+// command carries out one line of the protocol on the selected
+// display, which DISPLAY changes.
+//
+func (d *daemon) command(display **managedDisplay, command, argument string) (string, error) {
+	if command == "DISPLAY" {
+		selected, err := d.display(argument)
+		if err != nil {
+			return "", err
+		}
+		*display = selected
+		state := selected.State()
+		return state.Name + " " + strconv.Itoa(state.Width), nil
+	}
+
+	if *display == nil {
+		return "", fmt.Errorf("no display selected")
+	}
+
+	switch command {
+	case "WAIT":
+		(*display).Wait()
+		return "", nil
+	case "STATE":
+		state, err := json.Marshal((*display).State())
+		return string(state), err
+	}
+
+	action, ok := socketActions[command]
+	if !ok {
+		return "", fmt.Errorf("unknown command %s", command)
+	}
+	return "", (*display).Perform(action, argument)
+}
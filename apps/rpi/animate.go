@@ -18,22 +18,12 @@ package main
 
 import (
 	"fmt"
-	"log"
-	"os"
-	"os/signal"
 	"sort"
-	"syscall"
 	"time"
 
 	"github.com/wbeebe/rpi/devices"
 )
 
-// DefaultAddress is the default I2C address at which the device can be
-// addressed. It is in fact the lowest address any of these devices can
-// be addressed.
-//
-const DefaultAddress int = 0x70
-
 // An application for the Adafruit 0.8" 8x16 LED Matrix FeatherWing Display.
 //
 
@@ -149,77 +139,63 @@ func vt52(device *devices.Adafruit816LedMatrix) {
 	}
 }
 
-func help() {
-	helpText := []string{
-		"\n Adafruit 8x16 Featherwing Display utility\n",
-		" Command line actions:\n",
-		" faces  - Displays a series of three smiley faces.",
-		" shapes - Displays a series of simple glyphs.",
-		" scroll - Scrolls a selected glyph from left to right.",
-		"        - scroll by itself scrolls a smiley face.",
-		"        - 'animate scroll list' lists all glyphs.",
-		" vt52   - Displays all the old VT-52 ROM characters",
-		"        - translated to work with the Adafruit display.",
-		" wave   - Displays a scrolling triangle wave for 10 cycles.\n",
-		" No command - this help\n",
-	}
-
-	for _, line := range helpText {
-		fmt.Println(line)
-	}
+var animateHelp = []string{
+	"\n Adafruit 8x16 Featherwing Display utility\n",
+	" Usage: rpi animate action [argument]\n",
+	" Command line actions:\n",
+	" faces  - Displays a series of three smiley faces.",
+	" play   - Plays an animation sequence file passed as a second argument.",
+	"        - See sequence.go and examples/faces.json for the file format.",
+	" shapes - Displays a series of simple glyphs.",
+	" scroll - Scrolls a selected glyph from left to right.",
+	"        - scroll by itself scrolls a smiley face.",
+	"        - 'rpi animate scroll list' lists all glyphs.",
+	" vt52   - Displays all the old VT-52 ROM characters",
+	"        - translated to work with the Adafruit display.",
+	" wave   - Displays a scrolling triangle wave for 10 cycles.\n",
+	" No command - this help\n",
 }
 
-//
-//
-func main() {
-	ht16k33 := devices.NewHT16K33Driver(DefaultAddress)
-	af816 := devices.NewAdafruit816LedMatrix(ht16k33)
-
-	// Hook the various system abort calls for us to use or ignore as we
-	// see fit. In particular hook SIGINT, or CTRL+C for below.
-	//
-	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan,
-		syscall.SIGHUP,
-		syscall.SIGINT,
-		syscall.SIGTERM,
-		syscall.SIGQUIT)
-
-	err := ht16k33.Start()
+func runAnimate(o *options, args []string) error {
+	ht16k33, err := o.ht16k33(o.addressOr(defaultAddress))
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	af816 := devices.NewAdafruit816LedMatrix(ht16k33)
 
 	// We want to capture CTRL+C to first clear the display and then exit.
 	// We don't want to leave the display lit on an abort.
 	//
-	go func() {
-		for {
-			signal := <-signalChan
-			switch signal {
-			case syscall.SIGINT:
-				// CTRL+C
-				fmt.Println()
-				ht16k33.Clear()
-				ht16k33.Close()
-				os.Exit(0)
-			default:
-			}
-		}
-	}()
+	onInterrupt(func() {
+		ht16k33.Clear()
+		ht16k33.Close()
+	})
 
 	var action, argument string
 
-	if len(os.Args) > 1 {
-		action = os.Args[1]
+	if len(args) > 0 {
+		action = args[0]
 	}
-	if len(os.Args) > 2 {
-		argument = os.Args[2]
+	if len(args) > 1 {
+		argument = args[1]
 	}
 
 	switch action {
 	case "faces":
 		simpleAnimation(af816)
+	case "play":
+		if len(argument) == 0 {
+			fmt.Println(" play command needs an animation file.")
+			break
+		}
+
+		seq, err := loadSequence(argument)
+		if err != nil {
+			fmt.Printf(" %v\n", err)
+			break
+		}
+
+		playSequence(af816, seq)
 	case "scroll":
 		if len(argument) == 0 {
 			argument = "smile"
@@ -245,9 +221,10 @@ func main() {
 	case "vt52":
 		vt52(af816)
 	default:
-		help()
+		commandHelp(findCommand("animate"))
 	}
 
 	ht16k33.Clear()
 	ht16k33.Close()
+	return nil
 }
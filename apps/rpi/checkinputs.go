@@ -0,0 +1,104 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"gobot.io/x/gobot/platforms/raspi"
+
+	"github.com/wbeebe/rpi/devices"
+)
+
+// Reports button presses on GPB0-GPB7 of an MCP23017, the Go
+// replacement for I2Cpp/CheckInputs.c. Rather than reading every
+// pin every 100 ms, the MCP23017 raises an interrupt on any change
+// and each debounced press and release is printed as it happens.
+//
+// The buttons pull the pins to ground, so a press is a falling edge.
+//
+
+// defaultInputsAddress is the address of the inputs MCP23017, the
+// second MCP23017 in the I2Cpp designs.
+//
+const defaultInputsAddress int = devices.MCP23017_DEFAULT_ADDRESS + 1
+
+var checkInputsHelp = []string{
+	"\n Reports presses of buttons wired to GPB0-GPB7 of an MCP23017\n",
+	" Usage: rpi checkinputs [interrupt pin]\n",
+	"  interrupt pin - Raspberry Pi header pin wired to INTA or INTB.",
+	"                - Without it the MCP23017's interrupt flags are polled.",
+	" The MCP23017 is at 0x21 unless --address says otherwise.\n",
+	" Examples:",
+	" rpi checkinputs",
+	" rpi --address 0x20 checkinputs 11\n",
+}
+
+func runCheckInputs(o *options, args []string) error {
+	var interruptPin string
+	if len(args) > 0 {
+		interruptPin = args[0]
+	}
+
+	inputs, err := o.mcp23017(o.addressOr(defaultInputsAddress))
+	if err != nil {
+		return err
+	}
+
+	inputs.SetPortDirection(devices.PORT_B, 0xFF)
+	inputs.SetPortPullUps(devices.PORT_B, 0x00)
+
+	options := devices.WatchOptions{Pins: 0xFF00}
+
+	// INTA and INTB are active low.
+	//
+	if len(interruptPin) > 0 {
+		adapter := raspi.NewAdaptor()
+		adapter.Connect()
+		options.Interrupt = func() (bool, error) {
+			level, err := adapter.DigitalRead(interruptPin)
+			return level == 0, err
+		}
+	}
+
+	events, stop, err := inputs.Watch(options)
+	if err != nil {
+		return err
+	}
+
+	// We want to capture CTRL+C to stop watching, which turns the
+	// MCP23017's interrupts back off, and to wait for that before
+	// exiting.
+	//
+	stopped := make(chan struct{})
+	onInterrupt(func() {
+		stop()
+		<-stopped
+	})
+
+	for event := range events {
+		state := "pressed"
+		if event.High {
+			state = "released"
+		}
+		fmt.Printf(" %s  %s\n", event, state)
+	}
+
+	inputs.Close()
+	close(stopped)
+	return nil
+}
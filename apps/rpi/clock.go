@@ -0,0 +1,343 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wbeebe/rpi/devices"
+)
+
+// A clock that keeps its display open, in place of apps/clock.sh. It
+// shows the time, then the date, in turn, on the alphanumeric displays,
+// the 7-segment backpack, a matrix, or the DL1414s wired as for
+// rpi intdisplay.
+//
+// It is also a lab timer, with a countdown, a stopwatch with laps, and
+// alarms, worked by keys on the HT16K33's key scan, buttons on an
+// MCP23017, or the keyboard. The timers are in clocktimers.go, and the
+// displays in clockfaces.go.
+//
+
+var clockHelp = []string{
+	"\n A clock for the attached displays\n",
+	" Usage: rpi clock [display] [countdown time | stopwatch] [options]\n",
+	"  display         - alpha for one or two alphanumeric displays, the default,",
+	"                  - seven for the 7-segment backpack, matrix for the 8x16",
+	"                  - matrix, or dl1414 for the displays on the MCP23017.",
+	"  countdown time  - Counts down, such as from 5m or 1h30m, then blinks.",
+	"  stopwatch       - Times laps, printing each one.",
+	" The timers, and alarms, are worked by two buttons: start, which starts",
+	" and stops the timers, and lap, which takes a lap, or resets a stopped",
+	" timer. Either silences an alarm or an expired countdown. Run from a",
+	" terminal, the timers also take Enter as lap, and s then Enter as start.",
+	" Options:",
+	"  -12 or -24      - 12 or 24 hour time, 12 hour by default.",
+	"  -s              - Show seconds when they fit. The 7-segment backpack never does.",
+	"  -b              - Blink the colon, or the decimal points standing in for it.",
+	"  -d layout,...   - Date layouts to show in turn, written as Go writes",
+	"                  - Mon Jan 2 15:04:05 2006, such as \"Jan 2,01/02/06\".",
+	"  -n              - No date, only the time.",
+	"  -z zone,...     - Time zones to show in turn, such as Local,UTC or",
+	"                  - America/New_York. Each is named before its time.",
+	"  -t time         - How long to show the time and each date, default 3s.",
+	"  -A time,...     - Alarms, as 24 hour times such as 7:30,13:00, in the",
+	"                  - first -z zone, or local time without -z. They ring",
+	"                  - in every mode, the timers included.",
+	"  -k              - Keys on the display's HT16K33 key scan are the buttons,",
+	"                  - K1 with KS0 for start and K1 with KS1 for lap.",
+	"  -m [address]    - Buttons on GPB0 (start) and GPB1 (lap) of an MCP23017,",
+	"                  - at 0x21 unless given, as for rpi checkinputs.",
+	"  -h              - this help\n",
+	" The display is at 0x70, or the MCP23017 for dl1414 at 0x20, unless",
+	" --address says otherwise.\n",
+	" Examples:",
+	" rpi clock -24 -s -b",
+	" rpi clock seven -b -n",
+	" rpi clock dl1414 -z Local,UTC -d \"Mon Jan 2\"",
+	" rpi clock countdown 10m -k",
+	" rpi clock seven stopwatch -m 0x21",
+	" rpi clock -A 7:30 -m\n",
+}
+
+// DefaultClockHold is how long the time, and each date, is shown.
+//
+const DefaultClockHold = 3 * time.Second
+
+// openFace starts the display the clock is shown on.
+//
+func openFace(o *options, kind string) (face, error) {
+	if kind == "dl1414" {
+		mcp, err := o.mcp23017(o.addressOr(devices.MCP23017_DEFAULT_ADDRESS))
+		if err != nil {
+			return nil, err
+		}
+		display := devices.NewDL1414Display(mcp, devices.IntDisplayWiring())
+		return &textFace{display, display.Close, nil}, display.Start()
+	}
+
+	// As with rpi display, a second alphanumeric display at the next
+	// address is chained on the left.
+	//
+	address := o.addressOr(defaultAddress)
+	if kind == "alpha" {
+		alpha, err := o.alphanumeric(address)
+		if err != nil {
+			return nil, err
+		}
+		return newAlphaFace(alpha), nil
+	}
+
+	ht16k33, err := o.ht16k33(address)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case "seven":
+		return newSevenFace(devices.NewAdafruit7SegmentDisplay(ht16k33)), nil
+	case "matrix":
+		matrix := devices.NewAdafruit816LedMatrix(ht16k33)
+		return &textFace{matrix, matrix.Close, []*devices.HT16K33Driver{ht16k33}}, nil
+	}
+
+	ht16k33.Close()
+	return nil, fmt.Errorf(" Unknown display %s", kind)
+}
+
+// clock shows the time in each zone in turn, then one of the dates,
+// taking the next date each time round.
+//
+type clock struct {
+	face    face
+	format  clockFormat
+	blink   bool
+	zones   []*time.Location
+	dates   []string
+	hold    time.Duration
+	alarms  []*alarm
+	due     chan *alarm
+	buttons chan button
+	stops   []func()
+}
+
+// Close stops watching the buttons and clears the display.
+//
+func (c *clock) Close() {
+	for _, stop := range c.stops {
+		stop()
+	}
+	c.face.Close()
+}
+
+// showTime keeps the time up to date for the hold time, ticking every
+// half second when the colon blinks. The colon is lit for the first
+// half of each second.
+//
+func (c *clock) showTime(zone *time.Location) {
+	tick := time.Second
+	if c.blink {
+		tick = time.Second / 2
+	}
+
+	end := time.Now().Add(c.hold)
+	for now := time.Now(); now.Before(end); now = time.Now() {
+		colon := !c.blink || now.Nanosecond() < int(tick)
+		c.face.showTime(now.In(zone), c.format, colon)
+		c.pause(tick - time.Duration(now.UnixNano()%int64(tick)))
+	}
+}
+
+func (c *clock) run() {
+	for date := 0; ; date++ {
+		for _, zone := range c.zones {
+			if len(c.zones) > 1 {
+				c.face.showText(time.Now().In(zone).Format("MST"))
+				c.pause(time.Second)
+			}
+			c.showTime(zone)
+		}
+
+		if len(c.dates) > 0 {
+			c.face.showText(time.Now().In(c.zones[0]).Format(c.dates[date%len(c.dates)]))
+			c.pause(c.hold)
+		}
+	}
+}
+
+// parseZones loads a comma separated list of time zones.
+//
+func parseZones(text string) ([]*time.Location, error) {
+	var zones []*time.Location
+	for _, name := range strings.Split(text, ",") {
+		zone, err := time.LoadLocation(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, zone)
+	}
+	return zones, nil
+}
+
+func runClock(o *options, args []string) error {
+	c := &clock{
+		zones:   []*time.Location{time.Local},
+		hold:    DefaultClockHold,
+		buttons: make(chan button, 4),
+	}
+	kind := "alpha"
+	noDate := false
+	mode := "clock"
+	var length time.Duration
+	keys := false
+	buttons := 0
+
+	next := func(i int, what string) string {
+		if i+1 == len(args) {
+			log.Fatalf(" %s needs %s", args[i], what)
+		}
+		return args[i+1]
+	}
+
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-h":
+			commandHelp(findCommand("clock"))
+			return nil
+		case "alpha", "seven", "matrix", "dl1414":
+			kind = arg
+		case "countdown":
+			var err error
+			if length, err = time.ParseDuration(next(i, "a time")); err != nil {
+				log.Fatal(err)
+			}
+			mode = arg
+			i++
+		case "stopwatch":
+			mode = arg
+		case "-12":
+			c.format.twentyFour = false
+		case "-24":
+			c.format.twentyFour = true
+		case "-s":
+			c.format.seconds = true
+		case "-b":
+			c.blink = true
+		case "-n":
+			noDate = true
+		case "-d":
+			c.dates = strings.Split(next(i, "date layouts"), ",")
+			i++
+		case "-z":
+			zones, err := parseZones(next(i, "time zones"))
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.zones = zones
+			i++
+		case "-t":
+			hold, err := time.ParseDuration(next(i, "a time"))
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.hold = hold
+			i++
+		case "-A":
+			alarms, err := parseAlarms(next(i, "alarm times"))
+			if err != nil {
+				log.Fatal(err)
+			}
+			c.alarms = alarms
+			i++
+		case "-k":
+			keys = true
+		case "-m":
+			buttons = defaultInputsAddress
+			if i+1 < len(args) {
+				if value, err := strconv.ParseInt(args[i+1], 0, 32); err == nil {
+					buttons = int(value)
+					i++
+				}
+			}
+		default:
+			commandHelp(findCommand("clock"))
+			return fmt.Errorf(" Unknown option %s", arg)
+		}
+	}
+
+	face, err := openFace(o, kind)
+	if err != nil {
+		return err
+	}
+	c.face = face
+
+	// We want to capture CTRL+C to first clear the display and then exit.
+	// We don't want to leave the display lit on an abort.
+	//
+	onInterrupt(c.Close)
+
+	if keys {
+		drivers := face.ht16k33s()
+		if len(drivers) == 0 {
+			c.Close()
+			return fmt.Errorf(" The %s display has no key scan", kind)
+		}
+		if err := c.watchKeys(drivers[0]); err != nil {
+			c.Close()
+			return err
+		}
+	}
+	if buttons != 0 {
+		if err := c.watchButtons(o, buttons); err != nil {
+			c.Close()
+			return err
+		}
+	}
+	// Only the timers read the keyboard, so that a clock run in the
+	// background, as clock.sh was, isn't stopped for reading a terminal.
+	//
+	if mode != "clock" && isTerminal(os.Stdin) {
+		c.watchInput()
+	}
+
+	switch {
+	case noDate:
+		c.dates = nil
+	case c.dates == nil:
+		c.dates = defaultDates(face.Width())
+	}
+
+	if len(c.alarms) > 0 {
+		c.watchAlarms()
+	}
+
+	switch mode {
+	case "countdown":
+		c.countdown(length)
+		c.Close()
+	case "stopwatch":
+		c.stopwatch()
+	default:
+		c.run()
+	}
+	return nil
+}
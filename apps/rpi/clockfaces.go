@@ -0,0 +1,258 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wbeebe/rpi/devices"
+)
+
+// clockFormat says how the time is written.
+//
+type clockFormat struct {
+	twentyFour bool
+	seconds    bool
+}
+
+// layouts returns the ways of writing the time, most detailed first, so
+// that each display can use the first that fits.
+//
+func (f clockFormat) layouts() []string {
+	var layouts []string
+	if f.twentyFour {
+		if f.seconds {
+			layouts = append(layouts, "15:04:05")
+		}
+		return append(layouts, "15:04")
+	}
+
+	if f.seconds {
+		layouts = append(layouts, "3:04:05 PM")
+	}
+	return append(layouts, "3:04 PM", "3:04")
+}
+
+func textWidth(text string) int { return utf8.RuneCountInString(text) }
+
+// widestTime is a time whose hour is written as widely as any, 12 on a
+// 12 hour clock, for choosing layouts.
+//
+var widestTime = time.Date(2006, time.January, 2, 12, 0, 0, 0, time.UTC)
+
+// fitTime writes t in the first layout that fits in width, as measured,
+// padded on the left to right justify it. It returns false, with the
+// shortest layout, when none fit.
+//
+// A layout fits only if every hour of the day fits, so that seconds
+// don't come and go with the hour: 3:04:05 PM fits eight characters,
+// but 12:04:05 PM doesn't.
+//
+func fitTime(t time.Time, layouts []string, width int, measure func(string) int) (string, bool) {
+	for _, layout := range layouts {
+		text := t.Format(layout)
+		if measure(widestTime.Format(layout)) <= width {
+			return strings.Repeat(" ", width-measure(text)) + text, true
+		}
+	}
+	return t.Format(layouts[len(layouts)-1]), false
+}
+
+// face is a display the clock can show the time and messages on.
+//
+// showTime shows the time, with its colon lit or not so that it can
+// blink. showText shows text, scrolling it when it is too wide.
+// showCount shows a timer, such as 1:05.3, right justified, returning
+// false without showing it if it doesn't fit. blink blinks the whole
+// display, returning false if it can't. ht16k33s are the display's
+// HT16K33s, if it has any, for their key scan.
+//
+type face interface {
+	Width() int
+	showTime(t time.Time, format clockFormat, colon bool)
+	showText(text string)
+	showCount(text string) bool
+	blink(on bool) bool
+	ht16k33s() []*devices.HT16K33Driver
+	Clear()
+	Close()
+}
+
+// textFace is any TextDisplay, such as the DL1414s or a matrix, which
+// blanks its colons when they are off.
+//
+type textFace struct {
+	devices.TextDisplay
+	close   func()
+	drivers []*devices.HT16K33Driver
+}
+
+func (d *textFace) Close()                             { d.close() }
+func (d *textFace) ht16k33s() []*devices.HT16K33Driver { return d.drivers }
+
+// blink uses the HT16K33s' own blinking, at 2Hz.
+//
+func (d *textFace) blink(on bool) bool {
+	rate := devices.HT16K33_BLINK_OFF
+	if on {
+		rate = devices.HT16K33_BLINK_2HZ
+	}
+	for _, driver := range d.drivers {
+		driver.SetBlinkRate(rate)
+	}
+	return len(d.drivers) > 0
+}
+
+func (d *textFace) showTime(t time.Time, format clockFormat, colon bool) {
+	text, ok := fitTime(t, format.layouts(), d.Width(), textWidth)
+	if !ok {
+		d.Scroll(text)
+		return
+	}
+
+	if !colon {
+		text = strings.Replace(text, ":", " ", -1)
+	}
+	d.Write(text)
+}
+
+func (d *textFace) showCount(text string) bool {
+	if textWidth(text) > d.Width() {
+		return false
+	}
+	d.Write(strings.Repeat(" ", d.Width()-textWidth(text)) + text)
+	return true
+}
+
+func (d *textFace) showText(text string) {
+	if textWidth(text) <= d.Width() {
+		d.Write(text)
+	} else {
+		d.Scroll(text)
+	}
+}
+
+// alphaFace is the alphanumeric display, or a chain of them, which has
+// no colon, so the decimal point after the hours and after the minutes
+// stands in for it.
+//
+type alphaFace struct {
+	textFace
+	alpha *devices.Adafruit54AlphaDisplay
+}
+
+func newAlphaFace(alpha *devices.Adafruit54AlphaDisplay) *alphaFace {
+	drivers := []*devices.HT16K33Driver{alpha.HT16K33()}
+	if neighbor := alpha.NeighborDisplay(); neighbor != nil {
+		drivers = append(drivers, neighbor.HT16K33())
+	}
+	return &alphaFace{textFace{alpha, alpha.Close, drivers}, alpha}
+}
+
+// alphaWidth measures text with its colons and periods folded into
+// decimal points.
+//
+func alphaWidth(text string) int {
+	return textWidth(text) - strings.Count(text, ":") - strings.Count(text, ".")
+}
+
+// writePoints writes text with each colon or period lit, if lit is set,
+// on the decimal point of the character before it.
+//
+func (d *alphaFace) writePoints(text string, lit bool) {
+	var letters []rune
+	var points uint32
+	for _, letter := range text {
+		if letter != ':' && letter != '.' {
+			letters = append(letters, letter)
+		} else if lit && len(letters) > 0 {
+			points |= 1 << uint(len(letters)-1)
+		}
+	}
+	d.alpha.WriteWithDecimals(string(letters), points)
+}
+
+func (d *alphaFace) showTime(t time.Time, format clockFormat, colon bool) {
+	text, ok := fitTime(t, format.layouts(), d.Width(), alphaWidth)
+	if !ok {
+		d.Scroll(text)
+		return
+	}
+	d.writePoints(text, colon)
+}
+
+func (d *alphaFace) showCount(text string) bool {
+	if alphaWidth(text) > d.Width() {
+		return false
+	}
+	d.writePoints(strings.Repeat(" ", d.Width()-alphaWidth(text))+text, true)
+	return true
+}
+
+// sevenFace is the 7-segment backpack, with its own colon. There are only
+// four digits, so seconds are never shown, and the last decimal point
+// marks PM.
+//
+type sevenFace struct {
+	textFace
+	seven *devices.Adafruit7SegmentDisplay
+}
+
+func newSevenFace(seven *devices.Adafruit7SegmentDisplay) *sevenFace {
+	return &sevenFace{textFace{seven, seven.Close, []*devices.HT16K33Driver{seven.HT16K33()}}, seven}
+}
+
+// showCount relies on Write, which puts colons on the colon and
+// periods on the decimal points.
+//
+func (d *sevenFace) showCount(text string) bool {
+	if alphaWidth(text) > d.Width() {
+		return false
+	}
+	d.Write(text)
+	return true
+}
+
+func (d *sevenFace) showTime(t time.Time, format clockFormat, colon bool) {
+	hours := t.Hour()
+	if !format.twentyFour {
+		hours %= 12
+		if hours == 0 {
+			hours = 12
+		}
+	}
+
+	d.seven.WriteClock(hours, t.Minute(), colon)
+	if !format.twentyFour && t.Hour() >= 12 {
+		d.seven.SetDecimal(uint8(devices.SEVEN_SEGMENT_DIGITS-1), true)
+	}
+}
+
+// defaultDates are the date layouts shown in turn, in Go's reference
+// time, picked to suit the width of the display.
+//
+func defaultDates(width int) []string {
+	switch {
+	case width >= 16:
+		return []string{"Monday Jan 2", "2006-01-02"}
+	case width >= 8:
+		return []string{"Jan 2", "01/02/06"}
+	}
+	return []string{"Jan 2", "2006"}
+}
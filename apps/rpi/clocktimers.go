@@ -0,0 +1,378 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/wbeebe/rpi/devices"
+)
+
+// button is one of the two controls the timers use, whether it is a
+// key on the HT16K33's key scan, a button on an MCP23017 or a line
+// typed on the standard input.
+//
+// start starts and stops the stopwatch and countdown. lap takes a lap
+// while the stopwatch runs, and resets it, or the countdown, while it
+// is stopped. Either one silences an alarm.
+//
+type button int
+
+const (
+	buttonStart button = iota
+	buttonLap
+)
+
+// Timing for the timers: how often they are redrawn, how long a lap
+// time stays up, and how long an alarm or an expired countdown blinks
+// before giving up.
+//
+const (
+	timerTick = 100 * time.Millisecond
+	lapHold   = 2 * time.Second
+	ringTime  = time.Minute
+)
+
+// press passes a button press on to a running timer or a ringing
+// alarm. While only the time is shown nothing reads the buttons, so a
+// press that finds the buffer full is dropped rather than holding up
+// the goroutine watching the buttons.
+//
+func (c *clock) press(b button) {
+	select {
+	case c.buttons <- b:
+	default:
+	}
+}
+
+// watchKeys makes key 0 of the HT16K33 key scan, K1 and KS0, the start
+// button and key 1, K1 and KS1, the lap button.
+//
+func (c *clock) watchKeys(driver *devices.HT16K33Driver) error {
+	events, stop, err := driver.WatchKeys(0)
+	if err != nil {
+		return err
+	}
+	c.stops = append(c.stops, stop)
+
+	go func() {
+		for event := range events {
+			if event.Pressed && event.Key <= int(buttonLap) {
+				c.press(button(event.Key))
+			}
+		}
+	}()
+	return nil
+}
+
+// watchButtons makes GPB0 of an MCP23017 the start button and GPB1 the
+// lap button. As in rpi checkinputs, the buttons pull the pins to
+// ground, so a press is a falling edge.
+//
+func (c *clock) watchButtons(o *options, address int) error {
+	inputs, err := o.mcp23017(address)
+	if err != nil {
+		return err
+	}
+
+	inputs.SetPortDirection(devices.PORT_B, 0xFF)
+	inputs.SetPortPullUps(devices.PORT_B, 0x00)
+
+	events, stop, err := inputs.Watch(devices.WatchOptions{Pins: 0x0300})
+	if err != nil {
+		inputs.Close()
+		return err
+	}
+	c.stops = append(c.stops, stop)
+
+	go func() {
+		for event := range events {
+			if !event.High {
+				c.press(button(event.Pin - 8))
+			}
+		}
+		inputs.Close()
+	}()
+	return nil
+}
+
+// isTerminal reports whether a file is a terminal rather than a pipe,
+// a file or /dev/null.
+//
+func isTerminal(file *os.File) bool {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+	return errno == 0
+}
+
+// watchInput reads the standard input, where an empty line is the lap
+// button and a line starting with s the start button.
+//
+func (c *clock) watchInput() {
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if strings.HasPrefix(strings.TrimSpace(scanner.Text()), "s") {
+				c.press(buttonStart)
+			} else {
+				c.press(buttonLap)
+			}
+		}
+	}()
+}
+
+// durationText writes a duration as M:SS, or H:MM:SS from an hour, with
+// tenths of a second if asked.
+//
+func durationText(d time.Duration, tenths bool) string {
+	total := int64(d / (time.Second / 10))
+	seconds := total / 10
+
+	text := fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+	if seconds >= 3600 {
+		text = fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	if tenths {
+		text += fmt.Sprintf(".%d", total%10)
+	}
+	return text
+}
+
+// showCount shows a timer, with tenths of a second when asked and they
+// fit, scrolling it on a display too narrow for it.
+//
+func (c *clock) showCount(d time.Duration, tenths bool) {
+	if tenths && c.face.showCount(durationText(d, true)) {
+		return
+	}
+	if text := durationText(d, false); !c.face.showCount(text) {
+		c.face.showText(text)
+	}
+}
+
+// ring blinks whatever draw shows until a button is pressed, or for
+// ringTime. Displays without HT16K33s are blinked by clearing them and
+// drawing them again. Presses from before it rang are thrown away, so
+// that only a new one silences it.
+//
+func (c *clock) ring(draw func()) {
+	for drained := false; !drained; {
+		select {
+		case <-c.buttons:
+		default:
+			drained = true
+		}
+	}
+
+	draw()
+	hardware := c.face.blink(true)
+	defer c.face.blink(false)
+
+	ticker := time.NewTicker(time.Second / 4)
+	defer ticker.Stop()
+	timeout := time.After(ringTime)
+
+	for lit := true; ; {
+		select {
+		case <-c.buttons:
+			draw()
+			return
+		case <-timeout:
+			draw()
+			return
+		case <-ticker.C:
+			if !hardware {
+				if lit = !lit; lit {
+					draw()
+				} else {
+					c.face.Clear()
+				}
+			}
+		}
+	}
+}
+
+// countdown counts down from length, then blinks 0:00 until a button is
+// pressed. start pauses and resumes it, and lap, while it is paused,
+// starts it over.
+//
+func (c *clock) countdown(length time.Duration) {
+	remaining := length
+	running := true
+	last := time.Now()
+
+	ticker := time.NewTicker(timerTick)
+	defer ticker.Stop()
+
+	for remaining > 0 {
+		// Round up, so that 0:00 only shows once the time is up.
+		//
+		c.showCount(remaining+time.Second-1, false)
+
+		select {
+		case b := <-c.buttons:
+			switch {
+			case b == buttonStart:
+				running = !running
+			case !running:
+				remaining = length
+			}
+		case a := <-c.due:
+			c.ringAlarm(a)
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		if running {
+			remaining -= now.Sub(last)
+		}
+		last = now
+	}
+
+	c.ring(func() { c.showCount(0, false) })
+}
+
+// stopwatch runs until the program is stopped. start stops and starts
+// it. lap, while it runs, shows the lap time for a moment and prints it
+// along with the total, or, while it is stopped, resets it.
+//
+func (c *clock) stopwatch() {
+	var elapsed, lapStart time.Duration
+	var lapShown time.Time
+	laps := 0
+	running := true
+	last := time.Now()
+
+	ticker := time.NewTicker(timerTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case b := <-c.buttons:
+			switch {
+			case b == buttonStart:
+				running = !running
+			case running:
+				laps++
+				split := elapsed - lapStart
+				lapStart = elapsed
+				lapShown = time.Now()
+				fmt.Printf(" Lap %d  %s  %s\n", laps, durationText(split, true), durationText(elapsed, true))
+				c.showCount(split, true)
+			default:
+				elapsed, lapStart, laps = 0, 0, 0
+			}
+		case a := <-c.due:
+			c.ringAlarm(a)
+			lapShown = time.Time{}
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		if running {
+			elapsed += now.Sub(last)
+		}
+		last = now
+
+		if now.Sub(lapShown) > lapHold {
+			c.showCount(elapsed, true)
+		}
+	}
+}
+
+// alarm is a time of day to ring at, once a day.
+//
+type alarm struct {
+	hour   int
+	minute int
+	rung   time.Time
+}
+
+// parseAlarms parses a comma separated list of 24 hour times, such as
+// 7:30,13:00.
+//
+func parseAlarms(text string) ([]*alarm, error) {
+	var alarms []*alarm
+	for _, field := range strings.Split(text, ",") {
+		parts := strings.Split(strings.TrimSpace(field), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf(" Alarm %s is not HH:MM", field)
+		}
+		hour, err := strconv.Atoi(parts[0])
+		if err != nil || hour < 0 || hour > 23 {
+			return nil, fmt.Errorf(" Alarm %s has a bad hour", field)
+		}
+		minute, err := strconv.Atoi(parts[1])
+		if err != nil || minute < 0 || minute > 59 {
+			return nil, fmt.Errorf(" Alarm %s has a bad minute", field)
+		}
+		alarms = append(alarms, &alarm{hour: hour, minute: minute})
+	}
+	return alarms, nil
+}
+
+// watchAlarms checks the alarms every second, whatever the clock is
+// showing, and passes each one that is due, and hasn't rung today, to
+// whichever mode is running. Alarms are set in the first zone, as the
+// date is, whichever zone is showing.
+//
+func (c *clock) watchAlarms() {
+	c.due = make(chan *alarm)
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			now = now.In(c.zones[0])
+			for _, a := range c.alarms {
+				if now.Hour() == a.hour && now.Minute() == a.minute && now.Sub(a.rung) >= time.Hour {
+					a.rung = now
+					c.due <- a
+				}
+			}
+		}
+	}()
+}
+
+// ringAlarm rings an alarm, blinking the time in the alarms' zone.
+//
+func (c *clock) ringAlarm(a *alarm) {
+	fmt.Printf(" Alarm %d:%02d\n", a.hour, a.minute)
+	c.ring(func() { c.face.showTime(time.Now().In(c.zones[0]), c.format, true) })
+}
+
+// pause waits for a time, or rings an alarm that comes due before it is
+// up and returns once it is silenced.
+//
+func (c *clock) pause(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case a := <-c.due:
+		c.ringAlarm(a)
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Shell completion scripts, generated from the command table so that
+// they never fall behind it. Commands, their actions and the global
+// options are completed, and --bus offers the buses in /dev.
+//
+
+var completionHelp = []string{
+	"\n Prints a script that completes rpi's commands in bash or zsh\n",
+	" Usage: rpi completion bash|zsh\n",
+	" Examples:",
+	" source <(rpi completion bash)",
+	" rpi completion bash | sudo tee /etc/bash_completion.d/rpi",
+	" rpi completion zsh > \"${fpath[1]}/_rpi\"\n",
+}
+
+var globalOptions = []string{"--bus", "--address", "--sim", "--help"}
+
+// commandNames lists every command, and help.
+//
+func commandNames() []string {
+	var names []string
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	return append(names, "help")
+}
+
+func bashCompletion() string {
+	var cases strings.Builder
+	for _, c := range commands {
+		if len(c.actions) > 0 {
+			fmt.Fprintf(&cases, "    %s) words=\"%s\" ;;\n", c.name, strings.Join(c.actions, " "))
+		}
+	}
+	fmt.Fprintf(&cases, "    help) words=\"%s\" ;;\n", strings.Join(commandNames(), " "))
+
+	return `# bash completion for rpi, generated by 'rpi completion bash'.
+
+_rpi() {
+    local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}
+    local commands="` + strings.Join(commandNames(), " ") + `"
+    local command="" words="" word i
+
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        word=${COMP_WORDS[i]}
+        case $word in
+        --bus|--address) ((i++)) ;;
+        -*) ;;
+        *) command=$word; break ;;
+        esac
+    done
+
+    case $prev in
+    --bus)
+        words=$(ls /dev/i2c-* 2>/dev/null | sed 's|/dev/i2c-||')
+        COMPREPLY=($(compgen -W "$words" -- "$cur"))
+        return ;;
+    --address)
+        return ;;
+    play)
+        COMPREPLY=($(compgen -f -- "$cur"))
+        return ;;
+    esac
+
+    if [[ $cur == -* ]]; then
+        COMPREPLY=($(compgen -W "` + strings.Join(globalOptions, " ") + `" -- "$cur"))
+        return
+    fi
+
+    case $command in
+    "") words=$commands ;;
+` + cases.String() + `    esac
+
+    COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+
+complete -F _rpi rpi
+`
+}
+
+// zshDescribe quotes a command and its description for _describe,
+// which splits the two at the first unescaped colon.
+//
+func zshDescribe(name, description string) string {
+	text := name + ":" + strings.Replace(description, ":", "\\:", -1)
+	return "'" + strings.Replace(text, "'", "'\\''", -1) + "'"
+}
+
+func zshCompletion() string {
+	var described, cases strings.Builder
+	for _, c := range commands {
+		fmt.Fprintf(&described, "        %s\n", zshDescribe(c.name, strings.TrimSuffix(c.summary, ".")))
+		if len(c.actions) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&cases, "        %s)\n", c.name)
+		if c.name == "animate" {
+			fmt.Fprintf(&cases, "            [[ $words[CURRENT-1] == play ]] && { _files; return }\n")
+		}
+		fmt.Fprintf(&cases, "            compadd -- %s ;;\n", strings.Join(c.actions, " "))
+	}
+	fmt.Fprintf(&described, "        %s\n", zshDescribe("help", "Help for a command"))
+	fmt.Fprintf(&cases, "        help)\n            compadd -- %s ;;\n", strings.Join(commandNames(), " "))
+
+	return `#compdef rpi
+# zsh completion for rpi, generated by 'rpi completion zsh'.
+
+_rpi_buses() {
+    local -a buses
+    buses=(/dev/i2c-*(N:t))
+    compadd -- ${buses#i2c-}
+}
+
+_rpi() {
+    local -a commands
+    local state
+    commands=(
+` + described.String() + `    )
+
+    _arguments -C \
+        '--bus[the I2C bus]:bus:_rpi_buses' \
+        '--address[the device address]:address:' \
+        '--sim[use simulated devices]' \
+        '--help[help]' \
+        '1:command:->command' \
+        '*::argument:->argument'
+
+    case $state in
+    command)
+        _describe command commands ;;
+    argument)
+        case $words[1] in
+` + cases.String() + `        esac ;;
+    esac
+}
+
+_rpi "$@"
+`
+}
+
+func runCompletion(o *options, args []string) error {
+	shell := ""
+	if len(args) > 0 {
+		shell = args[0]
+	}
+
+	switch shell {
+	case "bash":
+		fmt.Print(bashCompletion())
+	case "zsh":
+		fmt.Print(zshCompletion())
+	default:
+		commandHelp(findCommand("completion"))
+	}
+	return nil
+}
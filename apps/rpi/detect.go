@@ -0,0 +1,260 @@
+/*
+Copyright (c) 2018 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wbeebe/rpi/devices"
+)
+
+// Surveys every I2C bus for chips and prints a grid of what it found,
+// in the style of i2cdetect, or JSON for scripts. Each chip found is
+// then looked up in the table of known parts in devices/I2CParts.go,
+// and identified by a safe probe where the part allows one.
+//
+// rpi detect watch rescans until stopped, printing each device as it
+// is connected or disconnected.
+//
+
+var detectHelp = []string{
+	"\n Surveys the I2C buses for devices\n",
+	" Usage: rpi detect [watch] [options] [bus ...]\n",
+	"  watch           - Rescan until CTRL+C, reporting devices added and removed.",
+	"  bus ...         - Bus numbers to scan, default --bus or every /dev/i2c-* bus.",
+	" Options:",
+	"  -i interval     - How often watch rescans, such as 500ms, default 2s.",
+	"  -a              - Also probe the reserved addresses 0x00-0x02 and 0x78-0x7f.",
+	"  -j              - Print JSON rather than a grid.",
+	"  -n              - No identification probes, only look up the known parts.",
+	"  -q [first-last] - Probe with quick writes, everywhere or over a range.",
+	"  -r [first-last] - Probe with reads, everywhere or over a range.",
+	"                  - By default 0x30-0x37 and 0x50-0x5f are read, so as not",
+	"                  - to upset EEPROMs, and everything else gets a quick write.",
+	"  With --address only that address is probed, and with --sim a",
+	"  simulated bus with an HT16K33 at 0x70 is scanned.\n",
+	" Examples:",
+	" rpi detect 1",
+	" rpi detect -r 0x40-0x4f -j",
+	" rpi detect watch -i 1s 1\n",
+}
+
+// parseRange parses an address range such as 0x50-0x57.
+//
+func parseRange(text string) (first, last int, ok bool) {
+	bounds := strings.Split(text, "-")
+	if len(bounds) != 2 {
+		return 0, 0, false
+	}
+
+	low, err := strconv.ParseInt(bounds[0], 0, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	high, err := strconv.ParseInt(bounds[1], 0, 32)
+	if err != nil || high < low {
+		return 0, 0, false
+	}
+
+	return int(low), int(high), true
+}
+
+// printDevices lists each device found with what it probably is.
+//
+func printDevices(scan devices.I2CBusScan) {
+	for _, device := range scan.Devices {
+		busy := ""
+		if device.Busy {
+			busy = " (in use by a kernel driver)"
+		}
+		fmt.Printf(" Found device at %s: %s%s\n", device, device.Description(), busy)
+	}
+	fmt.Println()
+}
+
+// watchBuses prints an event, or a line of JSON, for each device
+// added or removed, forever.
+//
+func watchBuses(scanner *devices.I2CScanner, interval time.Duration, asJSON bool) error {
+	events, _, err := scanner.Watch(interval)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for event := range events {
+		if asJSON {
+			encoder.Encode(event)
+		} else {
+			fmt.Printf(" %s\n", event)
+		}
+	}
+	return nil
+}
+
+// simulateBuses has the scanner open simulated buses, each with an
+// HT16K33 at the default address, for trying detect without hardware.
+//
+func simulateBuses(scanner *devices.I2CScanner) {
+	if len(scanner.Buses) == 0 {
+		scanner.Buses = []int{1}
+	}
+	scanner.Open = func(number int) (devices.I2CBus, error) {
+		bus := devices.NewSimulatedI2CBus(number)
+		bus.Attach(defaultAddress, devices.NewSimulatedConnection())
+		return bus, nil
+	}
+}
+
+// printGrid prints one bus the way i2cdetect does: the address of
+// each chip found, -- where nothing answered, UU where a kernel driver
+// has the address, and blanks where nothing was probed.
+//
+func printGrid(scan devices.I2CBusScan) {
+	fmt.Printf(" I2C bus %d\n", scan.Bus)
+	fmt.Println("     0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f")
+
+	for row := 0; row < devices.I2C_ADDRESSES; row += 16 {
+		fmt.Printf(" %02x:", row)
+		for address := row; address < row+16; address++ {
+			switch scan.States[address] {
+			case devices.ADDRESS_FOUND:
+				fmt.Printf(" %02x", address)
+			case devices.ADDRESS_BUSY:
+				fmt.Print(" UU")
+			case devices.ADDRESS_EMPTY:
+				fmt.Print(" --")
+			default:
+				fmt.Print("   ")
+			}
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+}
+
+func runDetect(o *options, args []string) error {
+	scanner := devices.NewI2CScanner()
+	asJSON := false
+	identify := true
+	watch := false
+	interval := devices.DEFAULT_SCAN_INTERVAL
+
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "watch":
+			watch = true
+		case "-i":
+			if i+1 == len(args) {
+				return fmt.Errorf(" -i needs an interval")
+			}
+			i++
+			newInterval, err := time.ParseDuration(args[i])
+			if err != nil {
+				return err
+			}
+			if newInterval <= 0 {
+				return fmt.Errorf(" -i %s is not a positive interval", args[i])
+			}
+			interval = newInterval
+		case "-a":
+			scanner.First = 0
+			scanner.Last = devices.I2C_ADDRESSES - 1
+		case "-j":
+			asJSON = true
+		case "-n":
+			identify = false
+		case "-q", "-r":
+			method := devices.PROBE_QUICK
+			if arg == "-r" {
+				method = devices.PROBE_READ
+			}
+
+			if i+1 < len(args) {
+				if first, last, ok := parseRange(args[i+1]); ok {
+					scanner.Ranges = append(scanner.Ranges, devices.ProbeRange{First: first, Last: last, Method: method})
+					i++
+					continue
+				}
+			}
+			scanner.Method = method
+		default:
+			bus, err := strconv.Atoi(arg)
+			if err != nil {
+				return fmt.Errorf(" Unknown detect option %s", arg)
+			}
+			scanner.Buses = append(scanner.Buses, bus)
+		}
+	}
+
+	if len(scanner.Buses) == 0 && o.bus != devices.I2C_DEFAULT_BUS {
+		scanner.Buses = []int{o.bus}
+	}
+	if o.address >= 0 {
+		scanner.First, scanner.Last = o.address, o.address
+	}
+	if o.sim {
+		simulateBuses(scanner)
+	}
+
+	onInterrupt(nil)
+
+	scanner.Identify = identify
+	if watch {
+		return watchBuses(scanner, interval, asJSON)
+	}
+
+	// Without probes, the devices found are only looked up in the table.
+	//
+	scans, err := scanner.Scan()
+	if err != nil {
+		return err
+	}
+	if !identify {
+		for i := range scans {
+			for j := range scans[i].Devices {
+				device := &scans[i].Devices[j]
+				for _, part := range devices.I2CPartsAt(device.Address) {
+					device.Candidates = append(device.Candidates, part.Name)
+				}
+			}
+		}
+	}
+
+	if asJSON {
+		for i := range scans {
+			if scans[i].Devices == nil {
+				scans[i].Devices = []devices.DetectedDevice{}
+			}
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(scans)
+	}
+
+	for _, scan := range scans {
+		printGrid(scan)
+		printDevices(scan)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+/*
+Copyright (c) 2018 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/wbeebe/rpi/devices"
+)
+
+var displayHelp = []string{
+	"\n For the Adafruit Quad Alphanumeric FeatherWing Display\n",
+	" Usage: rpi display action [argument]\n",
+	" Command line actions:",
+	"  bit #     - Takes a bit pattern in binary format, up to 16 bits long, and displays it on a single digit.",
+	"            - Leading binary zeros are not necessary.",
+	"            - 0000001010111011, which displays '@', and 1010111011 are equivalent.",
+	"  clear     - Clears all characters and turns off all segments.",
+	"            - Useful for turning off randomly lit segments while experimenting.",
+	"  numbers   - Counts from 0 to F simultaniously in all digits.",
+	"  print     - Prints a string passed as a second argument directly to the display.",
+	"            - Unlike other actions, the display is not cleared (turned off).",
+	"            - Call the clear command to turn off the displays.",
+	"  scroll    - Scrolls a message string passed as a second argument.",
+	"            - Messages with spaces will need to be quoted.",
+	"  segments  - Lights all segments individually including decimal point.",
+	"            - Outer segments are lit, then inner.",
+	"            - Hex value is displayed in first two digits, third digit displays corresponding individually lit segment.",
+	"  table     - Scrolls all defined alphanumeric entries in the internal mapping table across the display, right to left.",
+	"  tail      - Shows each line of the standard input as it arrives, printed if it fits, otherwise scrolled.",
+	"            - The last line is left on the display. Options:",
+	"            - -t time  How long to hold each printed line, default 1s.",
+	"            - -s       Scroll every line.",
+	"            - -d       Drop lines that arrive while another is showing, bar the latest.",
+	"            - -r       Keep ANSI escape sequences, which are otherwise removed.",
+	"  test      - Fully tests all characters, one at a time, left to right.",
+	"            - All segments, including decimal point, are lit.",
+	" No command - this help\n",
+	" A second display at the next address up is used as well if it answers.",
+	" When displayd is running, clear, print, scroll and tail are sent to it over",
+	" its socket, " + devices.DISPLAYD_SOCKET + ", rather than using the bus.\n",
+	" Examples:",
+	" rpi display bit 0000001010111011",
+	" rpi display scroll \"The quick brown fox\"",
+	" rpi display test",
+	" make 2>&1 | rpi display tail -d",
+	" rpi display clear\n",
+}
+
+// useDaemon carries out an action through displayd, which owns the
+// display while it is running. Only the actions that need nothing but
+// text can be sent to it; the rest drive individual segments. An error
+// from displayd is returned, so that rpi fails with it.
+//
+func useDaemon(client *devices.DisplayClient, action, argument string, args []string) error {
+	defer client.Close()
+
+	var err error
+	switch action {
+	case "clear":
+		_, err = client.Command("CLEAR", "")
+	case "print":
+		if len(argument) == 0 {
+			fmt.Println(" print command needs a string argument.")
+		} else {
+			_, err = client.Command("PRINT", argument)
+		}
+	case "scroll":
+		if len(argument) == 0 {
+			fmt.Printf(" scroll command needs a message to display.\n")
+		} else if _, err = client.Command("SCROLL", argument); err == nil {
+			_, err = client.Command("WAIT", "")
+		}
+	case "tail":
+		tail(client, os.Stdin, parseTail(args))
+	case "":
+		commandHelp(findCommand("display"))
+	default:
+		err = fmt.Errorf(" %s needs the display itself, which displayd is using. Stop displayd first", action)
+	}
+	return err
+}
+
+func runDisplay(o *options, args []string) error {
+	// Execute actions passed on the command line, along with any additional arguments.
+	//
+	var action, argument string
+
+	if len(args) > 0 {
+		action = args[0]
+	}
+	if len(args) == 2 {
+		argument = args[1]
+	}
+	var rest []string
+	if len(args) > 1 {
+		rest = args[1:]
+	}
+
+	// Hand the action to displayd if it is running, so as not to fight
+	// it for the display. Options for the bus and address are about the
+	// display itself, so they bypass displayd.
+	//
+	if o.bus == devices.I2C_DEFAULT_BUS && o.address < 0 && !o.sim {
+		if client, err := devices.NewDisplayClient(devices.DISPLAYD_SOCKET, ""); err == nil {
+			return useDaemon(client, action, argument, rest)
+		}
+	}
+
+	// A second alphanumeric display, at the next address up, is used
+	// as well if there is one.
+	//
+	af54, err := o.alphanumeric(o.addressOr(defaultAddress))
+	if err != nil {
+		return err
+	}
+
+	// Everything that only needs to show text goes through the TextDisplay
+	// interface, so it isn't tied to the alphanumeric display.
+	//
+	var text devices.TextDisplay = af54
+
+	fmt.Println(" Number of device digits: ", text.Width())
+
+	// We want to capture CTRL+C to first clear the display and then exit.
+	// We don't want to leave the display lit on an abort.
+	//
+	onInterrupt(af54.Close)
+
+	switch action {
+	case "bit":
+		if len(argument) == 0 {
+			fmt.Printf(" bit command needs a binary argument.\n")
+		} else {
+			af54.DisplayBinary(argument)
+		}
+	case "clear":
+		text.Clear()
+	case "numbers":
+		af54.NumbersTest()
+	case "print":
+		if len(argument) == 0 {
+			fmt.Println(" print command needs a string argument.")
+		} else {
+			text.Write(argument)
+		}
+	case "segments":
+		af54.CycleSegments()
+	case "scroll":
+		if len(argument) == 0 {
+			fmt.Printf(" scroll command needs a message to display.\n")
+		} else {
+			text.Scroll(argument)
+		}
+	case "table":
+		af54.ScrollAlphaTable()
+	case "tail":
+		tail(text, os.Stdin, parseTail(rest))
+	case "test":
+		af54.AllDigitSegmentTest()
+	default:
+		commandHelp(findCommand("display"))
+		//
+		// There is a corner case where, after power up, running display without any
+		// arguments to print out the instructions to the screen will leave any attached
+		// devices with randomly lit segments. This only occurs after power up.
+		// From now on getting help will also clear the display.
+		//
+		af54.Clear()
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/wbeebe/rpi/devices"
+)
+
+// The Go replacement for the HDSP-2111 tools in I2Cpp, driving a pair
+// of HDSP-2111 displays through an MCP23017 wired as in I2Cpp/HDSP.h.
+//
+// The bright, blink, flash, glyphs and selftest actions also need A3,
+// A4, /FL and /RD wired to a second MCP23017 at 0x21, as described by
+// devices.HDSPExtendedWiring.
+//
+
+var hdspHelp = []string{
+	"\n For a pair of HDSP-2111 displays wired as in I2Cpp/HDSP.h\n",
+	" Usage: rpi hdsp action [argument]\n",
+	" Command line actions:",
+	"  clock     - Shows the time on the left display, colons blinking. (SimpleClockHDSP)",
+	"  date      - Shows the time on the left display and the date on the right. (DateHDSP)",
+	"  print     - Prints a string passed as a second argument.",
+	"  reset     - Resets and blanks both displays. (ResetHDSP)",
+	"  run       - Runs a block cursor from right to left across both displays. (DisplayHDSP)",
+	"  scroll    - Scrolls a message string passed as a second argument.",
+	" With A3, A4, /FL and /RD wired to GPA0-GPA3 of a second MCP23017 at 0x21:",
+	"  bright    - Sets the brightness, 0 to 7, passed as a second argument.",
+	"  blink     - Prints a string passed as a second argument, blinking.",
+	"  flash     - Shows the time, flashing the seconds.",
+	"  glyphs    - Loads and shows user defined characters.",
+	"  selftest  - Runs each display's self-test and reports the result.",
+	" No command - this help\n",
+	" The MCP23017 is at 0x20 unless --address says otherwise, and the second",
+	" at the address after it.\n",
+	" Examples:",
+	" rpi hdsp scroll \"The quick brown fox\"",
+	" rpi hdsp bright 3",
+	" rpi hdsp date\n",
+}
+
+// runLeft fills the displays with character 0, a solid block, from the
+// right, then blanks them again the same way.
+//
+func runLeft(display *devices.HDSP2111Display) {
+	for _, char := range []byte{0, ' '} {
+		for i := display.Width() - 1; i >= 0; i-- {
+			display.WriteCharacter(i, char)
+			time.Sleep(25 * time.Millisecond)
+		}
+	}
+}
+
+// The user defined characters shown by the glyphs action, in the VT52
+// column format: a smiley, a heart, a bell, and up and down arrows.
+//
+var glyphs = [][]byte{
+	{0x22, 0x45, 0x41, 0x45, 0x22},
+	{0x18, 0x3c, 0x1e, 0x3c, 0x18},
+	{0x06, 0x3e, 0x7f, 0x3e, 0x06},
+	{0x10, 0x20, 0x7f, 0x20, 0x10},
+	{0x04, 0x02, 0x7f, 0x02, 0x04},
+}
+
+// showGlyphs loads the glyphs into UDC RAM, along with the VT52 degree
+// sign, and shows them on the left display.
+//
+func showGlyphs(display *devices.HDSP2111Display) error {
+	for index, columns := range glyphs {
+		if err := display.DefineCharacter(index, columns); err != nil {
+			return err
+		}
+	}
+
+	degree := len(glyphs)
+	if err := display.DefineVT52Character(degree, 0x07); err != nil {
+		return err
+	}
+
+	display.Clear()
+	for index := 0; index <= degree; index++ {
+		if err := display.WriteUserCharacter(index, index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hdspActions are every action runHDSP knows, which it checks before
+// starting any hardware, so that help needs none.
+//
+var hdspActions = []string{
+	"clock", "date", "print", "reset", "run", "scroll",
+	"bright", "blink", "flash", "glyphs", "selftest",
+}
+
+// extended reports whether an action needs the second MCP23017.
+//
+func extended(action string) bool {
+	switch action {
+	case "bright", "blink", "flash", "glyphs", "selftest":
+		return true
+	}
+	return false
+}
+
+func runHDSP(o *options, args []string) error {
+	var action, argument string
+
+	if len(args) > 0 {
+		action = args[0]
+	}
+	if len(args) == 2 {
+		argument = args[1]
+	}
+
+	if !contains(hdspActions, action) {
+		commandHelp(findCommand("hdsp"))
+		return nil
+	}
+
+	address := o.addressOr(devices.MCP23017_DEFAULT_ADDRESS)
+	mcp, err := o.mcp23017(address)
+	if err != nil {
+		return err
+	}
+	expanders := []*devices.MCP23017Driver{mcp}
+	wiring := devices.HDSPWiring()
+
+	if extended(action) {
+		second, err := o.mcp23017(address + 1)
+		if err != nil {
+			return err
+		}
+		expanders = append(expanders, second)
+		wiring = devices.HDSPExtendedWiring()
+	}
+
+	display := devices.NewHDSP2111Display(wiring, expanders...)
+	if err := display.Start(); err != nil {
+		return err
+	}
+
+	// We want to capture CTRL+C to first reset the displays and then exit.
+	// We don't want to leave the displays lit on an abort.
+	//
+	onInterrupt(display.Close)
+
+	switch action {
+	case "clock":
+		for {
+			display.WriteClock(0, time.Now(), true)
+			time.Sleep(time.Second - time.Duration(time.Now().Nanosecond()))
+		}
+	case "date":
+		for {
+			now := time.Now()
+			display.WriteClock(0, now, false)
+			display.WriteDate(devices.HDSP2111_CHARS, now)
+			time.Sleep(time.Second - time.Duration(time.Now().Nanosecond()))
+		}
+	case "print":
+		if len(argument) == 0 {
+			fmt.Println(" print command needs a string argument.")
+		} else {
+			display.Write(argument)
+		}
+	case "reset":
+		display.Reset()
+	case "run":
+		for i := 0; i < 9; i++ {
+			runLeft(display)
+		}
+		display.Reset()
+	case "scroll":
+		if len(argument) == 0 {
+			fmt.Printf(" scroll command needs a message to display.\n")
+		} else {
+			display.Scroll(argument)
+		}
+	case "bright":
+		level, err := strconv.Atoi(argument)
+		if err != nil {
+			fmt.Printf(" bright command needs a level from 0 to %d.\n", devices.HDSP2111_BRIGHTEST)
+			break
+		}
+		if err := display.SetBrightness(level); err != nil {
+			return err
+		}
+		display.Write(fmt.Sprintf("Bright %d", level))
+	case "blink":
+		if len(argument) == 0 {
+			fmt.Println(" blink command needs a string argument.")
+			break
+		}
+		display.Write(argument)
+		if err := display.SetBlink(true); err != nil {
+			return err
+		}
+	case "flash":
+		// The seconds are the last two characters of HH:MM:SS.
+		//
+		for _, location := range []int{6, 7} {
+			if err := display.SetFlash(location, true); err != nil {
+				return err
+			}
+		}
+		for {
+			display.WriteClock(0, time.Now(), false)
+			time.Sleep(time.Second - time.Duration(time.Now().Nanosecond()))
+		}
+	case "glyphs":
+		if err := showGlyphs(display); err != nil {
+			return err
+		}
+	case "selftest":
+		fmt.Println(" Running the self-test, which takes about five seconds.")
+		passed, err := display.SelfTest()
+		if err != nil {
+			return err
+		}
+		for i, ok := range passed {
+			result := "passed"
+			if !ok {
+				result = "FAILED"
+			}
+			fmt.Printf(" Display %d %s\n", i, result)
+		}
+		display.Reset()
+	}
+	return nil
+}
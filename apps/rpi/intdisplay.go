@@ -0,0 +1,89 @@
+/*
+Copyright (c) 2018 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"time"
+
+	"github.com/wbeebe/rpi/devices"
+)
+
+// An example application for driving either a DL2416 or DL1414
+// intelligent display with the MCP23017 I2C port expander on
+// a Raspberry Pi 3 B Plus. Capable of driving up to four
+// individual intelligent displays as coded and wired.
+//
+
+var intDisplayHelp = []string{
+	"\n For DL2416 or DL1414 intelligent displays wired to an MCP23017\n",
+	" Usage: rpi intdisplay\n",
+	" Shows a character test, scrolls every character, then shows the",
+	" date and time until CTRL+C. The MCP23017 is at 0x20 unless --address",
+	" says otherwise.\n",
+}
+
+// initialize finds the MCP23017 and sets up the displays wired to it.
+// The wiring, described by devices.IntDisplayWiring, allows for up to
+// four blocks of intelligent displays, with four characters/block, for
+// a total of 16 characters. GPIO B carries the character data. GPIO A
+// carries the character address within a block on its low two bits,
+// and the combined /WR/CE signal of each block on its upper nibble.
+//
+func initialize(o *options) (display *devices.DL1414Display, err error) {
+	device, err := o.mcp23017(o.addressOr(devices.MCP23017_DEFAULT_ADDRESS))
+	if err != nil {
+		return nil, err
+	}
+
+	display = devices.NewDL1414Display(device, devices.IntDisplayWiring())
+	return display, display.Start()
+}
+
+// basicClock writes the time to the two lowest blocks
+// (characters 0-7) and the date to the two highest blocks
+// (character 8-15)
+//
+func basicClock(display *devices.DL1414Display) {
+	for {
+		now := time.Now()
+		text := now.String()
+
+		display.Write(text[2:10] + text[11:19])
+		time.Sleep(1 * time.Second)
+	}
+}
+
+func runIntDisplay(o *options, args []string) error {
+	display, err := initialize(o)
+	if err != nil {
+		return err
+	}
+
+	// We want to capture CTRL+C to first clear the display and then exit.
+	// We don't want to leave the display lit on an abort.
+	//
+	onInterrupt(display.Close)
+
+	display.Write("CHARACTER TEST")
+	time.Sleep(3 * time.Second)
+
+	testchars := "!\"#$%&'<>*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_"
+	display.Scroll(testchars)
+
+	basicClock(display)
+	return nil
+}
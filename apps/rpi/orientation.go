@@ -0,0 +1,266 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wbeebe/rpi/devices"
+)
+
+// Shows the orientation from a BNO055 on one or two Adafruit Quad
+// Alphanumeric displays, and calibrates the BNO055, saving its offsets
+// so they can be restored the next time it starts.
+//
+
+var orientationHelp = []string{
+	"\n Shows a BNO055's orientation on the Adafruit Quad Alphanumeric FeatherWing Display\n",
+	" Usage: rpi orientation action [calibration file]\n",
+	" Command line actions:",
+	"  cycle     - Cycles through heading, pitch and roll, two seconds each.",
+	"  heading   - Shows the heading, 0 to 359 degrees.",
+	"  pitch     - Shows the pitch, -180 to 180 degrees.",
+	"  roll      - Shows the roll, -90 to 90 degrees.",
+	"  calibrate - Shows the system, gyroscope, accelerometer and magnetometer",
+	"            - calibration levels, 0 to 3, while the board is moved about.",
+	"            - Once all are 3 the offsets are saved to the calibration file.",
+	"  The calibration file is ~/.bno055-calibration unless passed as a second",
+	"  argument. Other actions restore the offsets from it when it exists.",
+	" No command - this help\n",
+	" The display is at 0x70 unless --address says otherwise, and the BNO055",
+	" at 0x28.\n",
+	" Examples:",
+	" rpi orientation cycle",
+	" rpi orientation calibrate /etc/bno055-calibration\n",
+}
+
+var orientationActions = []string{"cycle", "heading", "pitch", "roll", "calibrate"}
+
+// How often readings are shown, and how long each is shown when cycling.
+//
+const (
+	updateInterval = 100 * time.Millisecond
+	cycleInterval  = 2 * time.Second
+)
+
+// defaultCalibrationFile returns ~/.bno055-calibration.
+//
+func defaultCalibrationFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".bno055-calibration"
+	}
+	return filepath.Join(home, ".bno055-calibration")
+}
+
+// saveOffsets writes the offsets to a file as a line of hex.
+//
+func saveOffsets(path string, offsets devices.BNO055Offsets) error {
+	return ioutil.WriteFile(path, []byte(hex.EncodeToString(offsets[:])+"\n"), 0644)
+}
+
+// loadOffsets reads offsets written by saveOffsets.
+//
+func loadOffsets(path string) (offsets devices.BNO055Offsets, err error) {
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return offsets, err
+	}
+
+	data, err := hex.DecodeString(strings.TrimSpace(string(text)))
+	if err != nil {
+		return offsets, err
+	}
+	if len(data) != len(offsets) {
+		return offsets, fmt.Errorf(" %s holds %d bytes, not %d", path, len(data), len(offsets))
+	}
+
+	copy(offsets[:], data)
+	return offsets, nil
+}
+
+// formatReading fits a labelled angle to the display: "HDG  123" on
+// eight digits, "H123" on four, dropping the label when the angle
+// needs all four.
+//
+func formatReading(label string, degrees float64, width int) string {
+	if width >= 8 {
+		return fmt.Sprintf("%-3s%5.0f", label, degrees)
+	}
+
+	value := fmt.Sprintf("%.0f", degrees)
+	if len(value) >= width {
+		return value
+	}
+	return fmt.Sprintf("%s%*s", label[:1], width-1, value)
+}
+
+// showReading shows one angle from the BNO055.
+//
+func showReading(bno *devices.BNO055Driver, text devices.TextDisplay, which string) {
+	euler, err := bno.Euler()
+	if err != nil {
+		text.Write("ERR")
+		return
+	}
+
+	switch which {
+	case "heading":
+		text.Write(formatReading("HDG", euler.Heading, text.Width()))
+	case "pitch":
+		text.Write(formatReading("PIT", euler.Pitch, text.Width()))
+	case "roll":
+		text.Write(formatReading("ROL", euler.Roll, text.Width()))
+	}
+}
+
+// calibrate shows the calibration levels until the BNO055 is fully
+// calibrated, then saves the offsets.
+//
+func calibrate(bno *devices.BNO055Driver, text devices.TextDisplay, path string) error {
+	fmt.Println(" Move the board slowly through several positions, then in a figure eight.")
+
+	for last := ""; ; time.Sleep(updateInterval) {
+		status, err := bno.CalibrationStatus()
+		if err != nil {
+			return err
+		}
+
+		// S3G2A1M0 on eight digits, 3210 on four.
+		//
+		levels := status.String()
+		if text.Width() < 8 {
+			levels = fmt.Sprintf("%d%d%d%d", status.System, status.Gyroscope, status.Accelerometer, status.Magnetometer)
+		}
+		text.Write(strings.Replace(levels, " ", "", -1))
+
+		if levels != last {
+			fmt.Printf(" Calibration %s\n", status)
+			last = levels
+		}
+
+		if status.Calibrated() {
+			break
+		}
+	}
+
+	offsets, err := bno.CalibrationOffsets()
+	if err != nil {
+		return err
+	}
+	if err := saveOffsets(path, offsets); err != nil {
+		return err
+	}
+
+	fmt.Printf(" Saved the calibration offsets to %s\n", path)
+	text.Write("SAVED")
+	return nil
+}
+
+// bno055 starts the BNO055, or a simulated one with --sim, which unlike
+// the other devices needs to answer with its chip ID.
+//
+func (o *options) bno055() (*devices.BNO055Driver, error) {
+	bno := devices.NewBNO055Driver(devices.BNO055_DEFAULT_ADDRESS)
+	if o.sim {
+		return bno, bno.StartWithConnection(devices.NewSimulatedBNO055())
+	}
+
+	connection, err := o.connection(devices.BNO055_DEFAULT_ADDRESS)
+	if err != nil {
+		return nil, err
+	}
+	return bno, bno.StartWithConnection(connection)
+}
+
+func runOrientation(o *options, args []string) error {
+	var action, argument string
+
+	if len(args) > 0 {
+		action = args[0]
+	}
+	if len(args) == 2 {
+		argument = args[1]
+	}
+
+	if !contains(orientationActions, action) {
+		commandHelp(findCommand("orientation"))
+		return nil
+	}
+
+	path := argument
+	if len(path) == 0 {
+		path = defaultCalibrationFile()
+	}
+
+	af54, err := o.alphanumeric(o.addressOr(defaultAddress))
+	if err != nil {
+		return err
+	}
+
+	var text devices.TextDisplay = af54
+
+	bno, err := o.bno055()
+	if err != nil {
+		af54.Close()
+		return err
+	}
+
+	// We want to capture CTRL+C to first clear the display and put the
+	// BNO055 to sleep, and then exit.
+	//
+	onInterrupt(func() {
+		af54.Close()
+		bno.Close()
+	})
+
+	if action == "calibrate" {
+		if err := calibrate(bno, text, path); err != nil {
+			return err
+		}
+		bno.Close()
+		return nil
+	}
+
+	if offsets, err := loadOffsets(path); err == nil {
+		if err := bno.SetCalibrationOffsets(offsets); err != nil {
+			return err
+		}
+		fmt.Printf(" Restored the calibration offsets from %s\n", path)
+	} else if !os.IsNotExist(err) {
+		log.Println(err)
+	}
+
+	readings := []string{"heading", "pitch", "roll"}
+	started := time.Now()
+
+	for {
+		which := action
+		if action == "cycle" {
+			which = readings[int(time.Since(started)/cycleInterval)%len(readings)]
+		}
+		showReading(bno, text, which)
+		time.Sleep(updateInterval)
+	}
+}
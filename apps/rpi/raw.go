@@ -17,12 +17,7 @@ limitations under the License.
 package main
 
 import (
-	"fmt"
-	"log"
-	"os"
-	"os/signal"
 	"strconv"
-	"syscall"
 	"time"
 
 	"gobot.io/x/gobot/drivers/i2c"
@@ -30,11 +25,14 @@ import (
 	"github.com/wbeebe/rpi/devices"
 )
 
-// DefaultAddress is the default I2C address at which the device can be
-// addressed. It is in fact the lowest address any of these devices can
-// be addressed.
-//
-const DefaultAddress int = 0x70
+var rawHelp = []string{
+	"\n Lights every segment or LED of HT16K33 displays for two seconds each\n",
+	" Usage: rpi raw [address ...]\n",
+	"  address ...   - The displays to light, one after the other, default --address or 0x70.",
+	"                - If any one of them is not there, the rest are not lit.\n",
+	" Examples:",
+	" rpi raw 0x70 0x71\n",
+}
 
 func lightAll(device i2c.Connection) {
 	// First four digits for Alphanumeric and 8x16 Matrix
@@ -69,72 +67,51 @@ func lightAll(device i2c.Connection) {
 	device.WriteWordData(14, 0xFFFF)
 }
 
-func main() {
-	// Hook the various system abort calls for us to use or ignore as we
-	// see fit. In particular hook SIGINT, or CTRL+C for below.
-	//
-	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan,
-		syscall.SIGHUP,
-		syscall.SIGINT,
-		syscall.SIGTERM,
-		syscall.SIGQUIT)
-
+func runRaw(o *options, args []string) error {
 	// We can pass zero to many addresses on the command line.
-	// For example, 'raw_ht16k33 0x70 0x71' will turn on both
+	// For example, 'rpi raw 0x70 0x71' will turn on both
 	// displays, one after the other, if they are both attached.
-	// If either one is not there or unreachable, the application
+	// If either one is not there or unreachable, the command
 	// will abort.
 	//
 	var addresses []int
 
-	for _, arg := range os.Args[1:] {
-		if newAddress, err := strconv.ParseInt(arg, 0, 32); err == nil {
-			addresses = append(addresses, int(newAddress))
-		} else {
-			log.Fatal(err)
+	for _, arg := range args {
+		newAddress, err := strconv.ParseInt(arg, 0, 32)
+		if err != nil {
+			return err
 		}
+		addresses = append(addresses, int(newAddress))
 	}
 
 	// If nothing passed on the command line then use the default
-	// address/
+	// address.
 	//
 	if len(addresses) == 0 {
-		addresses = append(addresses, DefaultAddress)
+		addresses = append(addresses, o.addressOr(defaultAddress))
 	}
 
 	// We want to capture CTRL+C to first clear the display and then exit.
 	// We don't want to leave the display lit on an abort.
 	//
-	ht := devices.NewHT16K33Driver(DefaultAddress)
-
-	go func() {
-		for {
-			signal := <-signalChan
-			switch signal {
-			case syscall.SIGINT:
-				// CTRL+C
-				fmt.Println()
-				ht.Clear()
-				ht.Close()
-				os.Exit(0)
-			default:
-			}
+	var ht *devices.HT16K33Driver
+
+	onInterrupt(func() {
+		if ht != nil {
+			ht.Clear()
+			ht.Close()
 		}
-	}()
+	})
 
 	// Iterate over all the addresses passed on the command line (or not),
 	// aborting if any of the devices are unreachable.
 	//
-	for _, addr := range addresses {
-		address := int(addr)
-		ht16k33 := devices.NewHT16K33Driver(address)
-		ht = ht16k33
-
-		err := ht16k33.Start()
+	for _, address := range addresses {
+		ht16k33, err := o.ht16k33(address)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
+		ht = ht16k33
 
 		ht16k33.Clear()
 		lightAll(ht16k33.Connection())
@@ -142,4 +119,5 @@ func main() {
 		ht16k33.Clear()
 		ht16k33.Close()
 	}
+	return nil
 }
@@ -0,0 +1,331 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// One tool for the I2C devices, so that a Pi can be provisioned and
+// tested with a single binary. Each of the old detect, display,
+// animate, sweep, raw_ht16k33, intdisplay, clock, sysinfo, orientation,
+// hdsp and checkinputs apps is now a command, and they all take the
+// same --bus, --address and --sim options.
+//
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"gobot.io/x/gobot/drivers/i2c"
+
+	"github.com/wbeebe/rpi/devices"
+)
+
+// defaultAddress is where the HT16K33 displays are found unless told
+// otherwise. It is in fact the lowest address any of them can be at.
+//
+const defaultAddress int = 0x70
+
+// options are the global options every command takes.
+//
+type options struct {
+	bus     int
+	address int
+	sim     bool
+}
+
+// A command is one of the tools. Its help lines are printed by
+// 'rpi help <command>', followed by the global options, and its
+// actions are offered by shell completion.
+//
+type command struct {
+	name    string
+	summary string
+	help    []string
+	actions []string
+	run     func(o *options, args []string) error
+}
+
+// commands is filled in by init, as completion needs the table itself.
+//
+var commands []command
+
+func init() {
+	commands = []command{
+		{"animate", "Animations for the Adafruit 8x16 LED matrix.", animateHelp,
+			[]string{"faces", "play", "scroll", "shapes", "vt52", "wave"}, runAnimate},
+		{"checkinputs", "Reports presses of buttons wired to an MCP23017.", checkInputsHelp,
+			nil, runCheckInputs},
+		{"clock", "A clock and lab timer for the attached displays.", clockHelp,
+			[]string{"alpha", "seven", "matrix", "dl1414", "countdown", "stopwatch"}, runClock},
+		{"completion", "Prints a shell completion script.", completionHelp,
+			[]string{"bash", "zsh"}, runCompletion},
+		{"detect", "Surveys the I2C buses for devices.", detectHelp,
+			[]string{"watch"}, runDetect},
+		{"display", "For the Adafruit Quad Alphanumeric FeatherWing Display.", displayHelp,
+			[]string{"bit", "clear", "numbers", "print", "scroll", "segments", "table", "tail", "test"}, runDisplay},
+		{"hdsp", "For a pair of HDSP-2111 displays wired to an MCP23017.", hdspHelp,
+			hdspActions, runHDSP},
+		{"intdisplay", "A clock on DL1414 or DL2416 displays wired to an MCP23017.", intDisplayHelp,
+			nil, runIntDisplay},
+		{"orientation", "Shows a BNO055's orientation on the alphanumeric displays.", orientationHelp,
+			orientationActions, runOrientation},
+		{"raw", "Lights every LED of HT16K33 displays, one after another.", rawHelp,
+			nil, runRaw},
+		{"sweep", "Bounces a row of LEDs across the 8x16 LED matrix.", sweepHelp,
+			nil, runSweep},
+		{"sysinfo", "Shows facts about the Raspberry Pi.", sysinfoHelp,
+			[]string{"print"}, runSysinfo},
+	}
+}
+
+var optionsHelp = []string{
+	" Options for every command:",
+	"  --bus n       - The I2C bus, default the Pi's default bus, 1.",
+	"  --address a   - The device address, such as 0x71, default that of the command.",
+	"  --sim         - Use simulated devices, to try a command without hardware.\n",
+}
+
+func help() {
+	helpText := []string{
+		"\n Tools for the Raspberry Pi's I2C devices\n",
+		" Usage: rpi [options] command [arguments]\n",
+		" Commands:",
+	}
+	for _, c := range commands {
+		helpText = append(helpText, fmt.Sprintf("  %-12s - %s", c.name, c.summary))
+	}
+	helpText = append(helpText, "  help         - Help for a command, or this help.\n")
+	helpText = append(helpText, optionsHelp...)
+	helpText = append(helpText,
+		" Examples:",
+		" rpi detect",
+		" rpi --address 0x71 display scroll \"The quick brown fox\"",
+		" rpi help display\n")
+
+	for _, line := range helpText {
+		fmt.Println(line)
+	}
+}
+
+func commandHelp(c *command) {
+	for _, line := range c.help {
+		fmt.Println(line)
+	}
+	for _, line := range optionsHelp {
+		fmt.Println(line)
+	}
+}
+
+// contains reports whether a command's actions, or any other list,
+// include a word.
+//
+func contains(list []string, word string) bool {
+	for _, w := range list {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// parseOptions takes the global options out of args, wherever they are,
+// as either --bus 1 or --bus=1, and returns what is left.
+//
+func parseOptions(args []string) (*options, []string, error) {
+	o := &options{bus: devices.I2C_DEFAULT_BUS, address: -1}
+	var rest []string
+
+	for i := 0; i < len(args); i++ {
+		name, value := args[i], ""
+		hasValue := false
+		if strings.HasPrefix(name, "--") {
+			if at := strings.Index(name, "="); at > 0 {
+				name, value, hasValue = name[:at], name[at+1:], true
+			}
+		}
+
+		switch name {
+		case "--sim":
+			o.sim = true
+			continue
+		case "--bus", "--address":
+		default:
+			rest = append(rest, args[i])
+			continue
+		}
+
+		if !hasValue {
+			if i+1 == len(args) {
+				return nil, nil, fmt.Errorf(" %s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		number, err := strconv.ParseInt(value, 0, 32)
+		if err != nil || number < 0 {
+			return nil, nil, fmt.Errorf(" %s %s is not a number", name, value)
+		}
+		if name == "--bus" {
+			o.bus = int(number)
+		} else if int(number) >= devices.I2C_ADDRESSES {
+			return nil, nil, fmt.Errorf(" --address %s is not an I2C address", value)
+		} else {
+			o.address = int(number)
+		}
+	}
+
+	return o, rest, nil
+}
+
+// addressOr returns the --address option, or fallback without one.
+//
+func (o *options) addressOr(fallback int) int {
+	if o.address < 0 {
+		return fallback
+	}
+	return o.address
+}
+
+// connection opens a device on the --bus option, or a simulated device
+// with --sim.
+//
+func (o *options) connection(address int) (i2c.Connection, error) {
+	if o.sim {
+		return devices.NewSimulatedConnection(), nil
+	}
+	return devices.OpenI2CConnection(o.bus, address)
+}
+
+// ht16k33 starts an HT16K33 at an address.
+//
+func (o *options) ht16k33(address int) (*devices.HT16K33Driver, error) {
+	driver := devices.NewHT16K33Driver(address)
+	connection, err := o.connection(address)
+	if err != nil {
+		return nil, err
+	}
+	return driver, driver.StartWithConnection(connection)
+}
+
+// alphanumeric starts the Adafruit alphanumeric display at an address,
+// with a second display at the next address up chained on its left if
+// one answers, so that scroll and other aware functions use both.
+//
+func (o *options) alphanumeric(address int) (*devices.Adafruit54AlphaDisplay, error) {
+	ht16k33, err := o.ht16k33(address)
+	if err != nil {
+		return nil, err
+	}
+	af54 := devices.NewAdafruit54AlphaDisplay(ht16k33)
+
+	if ht16k33_2, err := o.ht16k33(address + 1); err == nil {
+		af54.SetNeighborDisplay(devices.NewAdafruit54AlphaDisplay(ht16k33_2))
+	}
+	return af54, nil
+}
+
+// mcp23017 starts an MCP23017 at an address.
+//
+func (o *options) mcp23017(address int) (*devices.MCP23017Driver, error) {
+	driver := devices.NewMCP23017Driver(address)
+	connection, err := o.connection(address)
+	if err != nil {
+		return nil, err
+	}
+	return driver, driver.StartWithConnection(connection)
+}
+
+// onInterrupt hooks the various system abort calls for us to use or
+// ignore as we see fit. We want to capture CTRL+C, and SIGTERM, from
+// kill or systemctl stop, to run cleanup, which clears the display, and
+// then exit. We don't want to leave the display lit on an abort.
+//
+func onInterrupt(cleanup func()) {
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan,
+		syscall.SIGHUP,
+		syscall.SIGINT,
+		syscall.SIGTERM,
+		syscall.SIGQUIT)
+
+	go func() {
+		for {
+			signal := <-signalChan
+			switch signal {
+			case syscall.SIGINT, syscall.SIGTERM:
+				// CTRL+C, kill or systemctl stop
+				fmt.Println()
+				if cleanup != nil {
+					cleanup()
+				}
+				os.Exit(0)
+			default:
+			}
+		}
+	}()
+}
+
+func main() {
+	o, args, err := parseOptions(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(args) == 0 {
+		help()
+		return
+	}
+
+	switch args[0] {
+	case "help", "-h", "--help":
+		if len(args) > 1 {
+			if c := findCommand(args[1]); c != nil {
+				commandHelp(c)
+				return
+			}
+		}
+		help()
+		return
+	}
+
+	c := findCommand(args[0])
+	if c == nil {
+		help()
+		log.Fatalf(" Unknown command %s", args[0])
+	}
+
+	if len(args) > 1 && (args[1] == "-h" || args[1] == "--help") {
+		commandHelp(c)
+		return
+	}
+
+	if err := c.run(o, args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
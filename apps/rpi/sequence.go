@@ -0,0 +1,403 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wbeebe/rpi/devices"
+)
+
+// Animation sequence files.
+//
+// A sequence file is a JSON document that describes an animation
+// as a list of frames, so that display content can be authored
+// without writing any Go. For example:
+//
+//	{
+//	    "name": "faces",
+//	    "loops": 3,
+//	    "duration": "500ms",
+//	    "frames": [
+//	        { "glyphs": ["face", "frown"] },
+//	        { "hex": "3c 42 a9 85 85 a9 42 3c", "transition": "scroll-left" },
+//	        { "vt52": "Hi", "duration": "1s" },
+//	        { "image": "heart.png", "repeat": 2, "transition": "wipe" }
+//	    ]
+//	}
+//
+// Each frame sets exactly one of:
+//
+//	hex    - 8 or 16 column bytes in hex. Eight bytes are shown on both halves.
+//	glyphs - One or two glyph names from 'rpi animate scroll list'.
+//	vt52   - One or two characters drawn with the VT52 font.
+//	image  - A GIF, JPEG or PNG file, 8 pixels high and 8 or 16 wide,
+//	         relative to the sequence file. Light pixels are lit.
+//
+// and may optionally set:
+//
+//	duration   - How long the frame is held, e.g. "250ms". Defaults to
+//	             the sequence duration, which itself defaults to 500ms.
+//	repeat     - How many times the frame is shown. Defaults to 1.
+//	transition - How the frame replaces the one before it: cut (the
+//	             default), scroll-left, scroll-right or wipe.
+//	step       - Time between transition steps. Defaults to 40ms.
+//
+// A sequence plays 'loops' times, or forever when loops is 0 or missing.
+//
+
+// MatrixColumns is the number of LED columns across the 8x16 matrix.
+// Each column is a single byte with the most significant bit at the top.
+//
+const MatrixColumns int = 16
+
+const defaultFrameDuration = 500 * time.Millisecond
+const defaultStepDuration = 40 * time.Millisecond
+
+// The on-disk form of a sequence and its frames.
+//
+type sequenceFile struct {
+	Name     string      `json:"name"`
+	Loops    int         `json:"loops"`
+	Duration string      `json:"duration"`
+	Frames   []frameSpec `json:"frames"`
+}
+
+type frameSpec struct {
+	Hex        string   `json:"hex"`
+	Glyphs     []string `json:"glyphs"`
+	VT52       string   `json:"vt52"`
+	Image      string   `json:"image"`
+	Duration   string   `json:"duration"`
+	Repeat     int      `json:"repeat"`
+	Transition string   `json:"transition"`
+	Step       string   `json:"step"`
+}
+
+// The playable form of a sequence, with every frame already
+// rendered to matrix columns.
+//
+type sequence struct {
+	name   string
+	loops  int
+	frames []frame
+}
+
+type frame struct {
+	columns    [MatrixColumns]byte
+	duration   time.Duration
+	repeat     int
+	transition string
+	step       time.Duration
+}
+
+// Transitions between one frame and the next.
+//
+const (
+	transitionCut         = "cut"
+	transitionScrollLeft  = "scroll-left"
+	transitionScrollRight = "scroll-right"
+	transitionWipe        = "wipe"
+)
+
+// loadSequence reads and validates a sequence file, rendering every
+// frame so that errors are reported before anything is displayed.
+//
+func loadSequence(path string) (*sequence, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file sequenceFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	if len(file.Frames) == 0 {
+		return nil, fmt.Errorf("%s: sequence has no frames", path)
+	}
+
+	if file.Loops < 0 {
+		return nil, fmt.Errorf("%s: loops can not be negative", path)
+	}
+
+	defaultDuration, err := parseDuration(file.Duration, defaultFrameDuration)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	seq := &sequence{name: file.Name, loops: file.Loops}
+	baseDir := filepath.Dir(path)
+
+	for i, spec := range file.Frames {
+		f, err := spec.compile(baseDir, defaultDuration)
+		if err != nil {
+			return nil, fmt.Errorf("%s: frame %d: %v", path, i+1, err)
+		}
+		seq.frames = append(seq.frames, f)
+	}
+
+	return seq, nil
+}
+
+func parseDuration(value string, fallback time.Duration) (time.Duration, error) {
+	if len(value) == 0 {
+		return fallback, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("duration %s can not be negative", value)
+	}
+
+	return duration, nil
+}
+
+func (spec frameSpec) compile(baseDir string, defaultDuration time.Duration) (f frame, err error) {
+	sources := 0
+	for _, set := range []bool{len(spec.Hex) > 0, len(spec.Glyphs) > 0, len(spec.VT52) > 0, len(spec.Image) > 0} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return f, fmt.Errorf("exactly one of hex, glyphs, vt52 or image is required")
+	}
+
+	switch {
+	case len(spec.Hex) > 0:
+		f.columns, err = hexColumns(spec.Hex)
+	case len(spec.Glyphs) > 0:
+		f.columns, err = glyphColumns(spec.Glyphs)
+	case len(spec.VT52) > 0:
+		f.columns, err = vt52Columns(spec.VT52)
+	default:
+		imagePath := spec.Image
+		if !filepath.IsAbs(imagePath) {
+			imagePath = filepath.Join(baseDir, imagePath)
+		}
+		f.columns, err = imageColumns(imagePath)
+	}
+	if err != nil {
+		return f, err
+	}
+
+	if f.duration, err = parseDuration(spec.Duration, defaultDuration); err != nil {
+		return f, err
+	}
+	if f.step, err = parseDuration(spec.Step, defaultStepDuration); err != nil {
+		return f, err
+	}
+
+	f.repeat = spec.Repeat
+	if f.repeat == 0 {
+		f.repeat = 1
+	}
+	if f.repeat < 0 {
+		return f, fmt.Errorf("repeat can not be negative")
+	}
+
+	f.transition = spec.Transition
+	switch f.transition {
+	case "":
+		f.transition = transitionCut
+	case transitionCut, transitionScrollLeft, transitionScrollRight, transitionWipe:
+	default:
+		return f, fmt.Errorf("unknown transition %q", spec.Transition)
+	}
+
+	return f, nil
+}
+
+// halves places one or two 8 column blocks across the display.
+// A single block is shown on both halves, like the shapes action.
+//
+func halves(blocks ...[]byte) (columns [MatrixColumns]byte) {
+	copy(columns[0:8], blocks[0])
+	copy(columns[8:16], blocks[len(blocks)-1])
+	return columns
+}
+
+func hexColumns(value string) (columns [MatrixColumns]byte, err error) {
+	cleaned := strings.NewReplacer(" ", "", ",", "", "0x", "", "0X", "").Replace(value)
+
+	bits, err := hex.DecodeString(cleaned)
+	if err != nil {
+		return columns, fmt.Errorf("bad hex %q: %v", value, err)
+	}
+
+	switch len(bits) {
+	case 8:
+		return halves(bits), nil
+	case MatrixColumns:
+		copy(columns[:], bits)
+		return columns, nil
+	}
+
+	return columns, fmt.Errorf("hex needs 8 or 16 bytes, found %d", len(bits))
+}
+
+func glyphColumns(names []string) (columns [MatrixColumns]byte, err error) {
+	if len(names) > 2 {
+		return columns, fmt.Errorf("at most two glyphs fit on the display")
+	}
+
+	var blocks [][]byte
+	for _, name := range names {
+		glyph, exist := shapeTable[name]
+		if !exist {
+			return columns, fmt.Errorf("glyph %s does not exist", name)
+		}
+		blocks = append(blocks, *glyph)
+	}
+
+	return halves(blocks...), nil
+}
+
+func vt52Columns(text string) (columns [MatrixColumns]byte, err error) {
+	var blocks [][]byte
+	for _, char := range text {
+		if char > 0x7F {
+			return columns, fmt.Errorf("character %q is not in the VT52 font", char)
+		}
+		blocks = append(blocks, devices.GetVT52Character(int(char)))
+	}
+
+	if len(blocks) > 2 {
+		return columns, fmt.Errorf("at most two VT52 characters fit on the display")
+	}
+
+	return halves(blocks...), nil
+}
+
+func imageColumns(path string) (columns [MatrixColumns]byte, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return columns, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return columns, fmt.Errorf("%s: %v", path, err)
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dy() != 8 || (bounds.Dx() != 8 && bounds.Dx() != MatrixColumns) {
+		return columns, fmt.Errorf("%s: image must be 8 high and 8 or 16 wide, found %dx%d",
+			path, bounds.Dx(), bounds.Dy())
+	}
+
+	bits := make([]byte, bounds.Dx())
+	for x := 0; x < bounds.Dx(); x++ {
+		for y := 0; y < 8; y++ {
+			pixel := img.At(bounds.Min.X+x, bounds.Min.Y+y)
+			if _, _, _, alpha := pixel.RGBA(); alpha == 0 {
+				continue
+			}
+			if color.GrayModel.Convert(pixel).(color.Gray).Y >= 0x80 {
+				bits[x] |= 0x80 >> uint(y)
+			}
+		}
+	}
+
+	if len(bits) == 8 {
+		return halves(bits), nil
+	}
+	copy(columns[:], bits)
+	return columns, nil
+}
+
+// playSequence shows every frame of a sequence in order, for as many
+// loops as the sequence asks for.
+//
+func playSequence(device *devices.Adafruit816LedMatrix, seq *sequence) {
+	var current [MatrixColumns]byte
+
+	for loop := 0; seq.loops == 0 || loop < seq.loops; loop++ {
+		for _, f := range seq.frames {
+			for r := 0; r < f.repeat; r++ {
+				transition(device, current, f)
+				current = f.columns
+				time.Sleep(f.duration)
+			}
+		}
+	}
+}
+
+func drawColumns(device *devices.Adafruit816LedMatrix, columns [MatrixColumns]byte) {
+	device.LoadBuffer(columns[:], 0)
+	device.DrawBuffer()
+}
+
+// transition moves from the columns currently displayed to the next
+// frame's columns, one column per step.
+//
+func transition(device *devices.Adafruit816LedMatrix, from [MatrixColumns]byte, to frame) {
+	if to.transition == transitionCut {
+		drawColumns(device, to.columns)
+		return
+	}
+
+	var columns [MatrixColumns]byte
+
+	for step := 1; step <= MatrixColumns; step++ {
+		for i := range columns {
+			switch to.transition {
+			case transitionScrollLeft:
+				if i+step < MatrixColumns {
+					columns[i] = from[i+step]
+				} else {
+					columns[i] = to.columns[i+step-MatrixColumns]
+				}
+			case transitionScrollRight:
+				if i-step >= 0 {
+					columns[i] = from[i-step]
+				} else {
+					columns[i] = to.columns[i-step+MatrixColumns]
+				}
+			case transitionWipe:
+				if i < step {
+					columns[i] = to.columns[i]
+				} else {
+					columns[i] = from[i]
+				}
+			}
+		}
+
+		drawColumns(device, columns)
+		if step < MatrixColumns {
+			time.Sleep(to.step)
+		}
+	}
+}
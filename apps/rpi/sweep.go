@@ -0,0 +1,91 @@
+/*
+Copyright (c) 2018 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"time"
+
+	"gobot.io/x/gobot/drivers/i2c"
+)
+
+var sweepHelp = []string{
+	"\n For the Adafruit 8x16 LED Matrix FeatherWing Display\n",
+	" Usage: rpi sweep\n",
+	" Bounces a row of LEDs down and back up six times, then clears the display.\n",
+}
+
+// A very simple test for the Adafruit 8x16 LED Matrix FeatherWing Display.
+// "Bounces" a row of lit LEDs from top to bottom and back to the top,
+// left to right, leaving a single straight line of lit LEDs across
+// the top of the display.
+//
+func bounce(device i2c.Connection) {
+	buffer := make([]byte, 16)
+	upDirection := make([]bool, 16)
+	altIndex := []int{0, 2, 4, 6, 8, 10, 12, 14, 1, 3, 5, 7, 9, 11, 13, 15}
+
+	for i := range buffer {
+		buffer[i] = 0x80
+	}
+
+	for i := 0; i < 2*len(buffer); i++ {
+		device.WriteBlockData(0, buffer)
+
+		if i > 0 {
+			time.Sleep(25 * time.Millisecond)
+		}
+
+		for j := i; j >= 0; j-- {
+			if j < len(buffer) && buffer[altIndex[j]] > 1 && !upDirection[altIndex[j]] {
+				buffer[altIndex[j]] >>= 1
+			} else if j < len(buffer) && buffer[altIndex[j]] == 1 {
+				upDirection[altIndex[j]] = true
+			}
+
+			if j < len(buffer) && buffer[altIndex[j]] < 0x80 && upDirection[altIndex[j]] {
+				buffer[altIndex[j]] <<= 1
+			}
+		}
+	}
+}
+
+func runSweep(o *options, args []string) error {
+	ht16k33, err := o.ht16k33(o.addressOr(defaultAddress))
+	if err != nil {
+		return err
+	}
+
+	// We want to capture CTRL+C to first clear the display and then exit.
+	// We don't want to leave the display lit on an abort.
+	//
+	onInterrupt(func() {
+		ht16k33.Clear()
+		ht16k33.Close()
+	})
+
+	ht16k33.Clear()
+
+	for i := 0; i < 6; i++ {
+		bounce(ht16k33.Connection())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	ht16k33.Clear()
+	ht16k33.Close()
+	return nil
+}
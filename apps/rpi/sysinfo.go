@@ -0,0 +1,209 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/wbeebe/rpi/devices"
+)
+
+// The Go replacement for shell/rpinfo.sh. It reads the board's model,
+// CPU, memory, temperature, load and throttling, then either prints
+// them, or shows them one after another on the alphanumeric displays,
+// reading the ones that change again each time round.
+//
+
+var sysinfoHelp = []string{
+	"\n Shows facts about the Raspberry Pi\n",
+	" Usage: rpi sysinfo [print] [options]\n",
+	"  print      - Print the facts, rather than cycling them on the displays.",
+	" Options:",
+	"  -t time    - How long to hold each fact that fits on the display, default 2s.",
+	"  -r root    - Read the files under root rather than /, such as a copy of",
+	"             - /proc and /sys taken from another board.\n",
+	" The facts are shown on the alphanumeric display at 0x70, unless --address",
+	" says otherwise, or through displayd when it is running.\n",
+	" Examples:",
+	" rpi sysinfo print",
+	" rpi sysinfo -t 3s\n",
+}
+
+// DefaultFactHold is how long each fact that fits is shown.
+//
+const DefaultFactHold = 2 * time.Second
+
+// gigabytes writes a number of bytes as gigabytes.
+//
+func gigabytes(bytes uint64) string {
+	return strconv.FormatFloat(float64(bytes)/(1<<30), 'f', 1, 64) + " GB"
+}
+
+// facts lists what is shown on the display, in turn.
+//
+func facts(info devices.SystemInfo) []string {
+	var list []string
+	if len(info.Model) > 0 {
+		list = append(list, info.Model)
+	}
+	list = append(list, fmt.Sprintf("%s x%d", info.CPU, info.Cores))
+	if len(info.Revision) > 0 {
+		list = append(list, "Rev "+info.Revision)
+	}
+	list = append(list,
+		"Mem "+gigabytes(info.MemTotal),
+		"Free "+gigabytes(info.MemAvailable))
+	if info.HasTemperature {
+		list = append(list, fmt.Sprintf("%.1fC", info.Temperature))
+	}
+	list = append(list, fmt.Sprintf("Load %.2f", info.Load[0]))
+	if info.HasThrottled {
+		list = append(list, "Throttle "+info.Throttled.String())
+	}
+	return append(list, "Linux "+info.Kernel)
+}
+
+// printFacts prints the facts the way shell/rpinfo.sh did.
+//
+func printFacts(info devices.SystemInfo) {
+	fmt.Printf("\n %s\n\n", info.Model)
+
+	lines := []struct{ name, value string }{
+		{"CPU Type", info.CPU},
+		{"Core Count", strconv.Itoa(info.Cores)},
+		{"Hardware", info.Hardware},
+		{"Revision", info.Revision},
+		{"MemTotal", gigabytes(info.MemTotal)},
+		{"MemAvailable", gigabytes(info.MemAvailable)},
+		{"Kernel Release", info.Kernel},
+		{"Load Average", fmt.Sprintf("%.2f %.2f %.2f", info.Load[0], info.Load[1], info.Load[2])},
+	}
+	if info.HasTemperature {
+		lines = append(lines, struct{ name, value string }{"Temperature", fmt.Sprintf("%.1f C", info.Temperature)})
+	}
+	if info.HasThrottled {
+		lines = append(lines, struct{ name, value string }{"Throttling", info.Throttled.String()})
+	}
+
+	for _, line := range lines {
+		if len(line.value) > 0 {
+			fmt.Printf(" %16s : %s\n", line.name, line.value)
+		}
+	}
+	fmt.Println()
+}
+
+// cycle shows each fact in turn, forever, printing those that fit and
+// scrolling the rest.
+//
+func cycle(text devices.TextDisplay, info devices.SystemInfo, paths devices.SystemInfoPaths, hold time.Duration) {
+	for {
+		for _, fact := range facts(info) {
+			if len(fact) > text.Width() {
+				text.Scroll(fact)
+			} else {
+				text.Write(fact)
+				time.Sleep(hold)
+			}
+		}
+
+		if err := info.Refresh(paths); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// openFactsDisplay uses displayd if it is running, and otherwise the
+// alphanumeric displays. As for rpi display, options for the bus and
+// address are about the display itself, so they bypass displayd.
+// closeDisplay clears and closes it.
+//
+func openFactsDisplay(o *options) (text devices.TextDisplay, closeDisplay func(), err error) {
+	if o.bus == devices.I2C_DEFAULT_BUS && o.address < 0 && !o.sim {
+		if client, err := devices.NewDisplayClient(devices.DISPLAYD_SOCKET, ""); err == nil {
+			return client, func() { client.Clear(); client.Close() }, nil
+		}
+	}
+
+	alpha, err := o.alphanumeric(o.addressOr(defaultAddress))
+	if err != nil {
+		return nil, nil, err
+	}
+	return alpha, alpha.Close, nil
+}
+
+func runSysinfo(o *options, args []string) error {
+	paths := devices.DefaultSystemInfoPaths()
+	hold := DefaultFactHold
+	printOnly := false
+
+	next := func(i int, what string) string {
+		if i+1 == len(args) {
+			log.Fatalf(" %s needs %s", args[i], what)
+		}
+		return args[i+1]
+	}
+
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-h":
+			commandHelp(findCommand("sysinfo"))
+			return nil
+		case "print":
+			printOnly = true
+		case "-t":
+			newHold, err := time.ParseDuration(next(i, "a time"))
+			if err != nil {
+				log.Fatal(err)
+			}
+			hold = newHold
+			i++
+		case "-r":
+			paths = devices.SystemInfoPathsUnder(next(i, "a directory"))
+			i++
+		default:
+			commandHelp(findCommand("sysinfo"))
+			return fmt.Errorf(" Unknown option %s", arg)
+		}
+	}
+
+	info, err := devices.ReadSystemInfo(paths)
+	if err != nil {
+		return err
+	}
+
+	if printOnly {
+		printFacts(info)
+		return nil
+	}
+
+	text, closeDisplay, err := openFactsDisplay(o)
+	if err != nil {
+		return err
+	}
+
+	// We want to capture CTRL+C to first clear the display and then exit.
+	// We don't want to leave the display lit on an abort.
+	//
+	onInterrupt(closeDisplay)
+
+	cycle(text, info, paths, hold)
+	return nil
+}
@@ -0,0 +1,153 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/wbeebe/rpi/devices"
+)
+
+// How display tail shows each line read from the standard input.
+//
+// A line that fits on the display is printed and held for hold, unless
+// scroll is set, and a longer line is scrolled. With drop set, lines
+// that arrive while one is showing are dropped, bar the latest, so the
+// display keeps up with a fast log. ANSI escape sequences, such as the
+// colors in build output, are removed unless raw is set.
+//
+type tailOptions struct {
+	hold   time.Duration
+	scroll bool
+	drop   bool
+	raw    bool
+}
+
+// DefaultTailHold is how long display tail holds a printed line.
+//
+const DefaultTailHold = time.Second
+
+// ansiEscape matches CSI sequences, such as colors and cursor movement,
+// OSC sequences, such as window titles, and other two byte escapes.
+//
+var ansiEscape = regexp.MustCompile(`\x1b(\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)|[@-Z\\-_])`)
+
+// cleanLine removes escape sequences, unless raw, and turns tabs and
+// any other control characters into spaces.
+//
+func cleanLine(line string, raw bool) string {
+	if !raw {
+		line = ansiEscape.ReplaceAllString(line, "")
+	}
+
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, line))
+}
+
+// readLines sends each line read to the returned channel, closing it at
+// the end of the input. With drop set, a line still waiting when the
+// next arrives is replaced by it.
+//
+func readLines(input io.Reader, drop bool) <-chan string {
+	size := 64
+	if drop {
+		size = 1
+	}
+	lines := make(chan string, size)
+
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(input)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !drop {
+				lines <- line
+				continue
+			}
+
+			select {
+			case lines <- line:
+			default:
+				select {
+				case <-lines:
+				default:
+				}
+				lines <- line
+			}
+		}
+	}()
+
+	return lines
+}
+
+// parseTail parses the options that follow tail on the command line.
+//
+func parseTail(args []string) tailOptions {
+	options := tailOptions{hold: DefaultTailHold}
+
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-t":
+			if i+1 == len(args) {
+				log.Fatal(" -t needs a hold time")
+			}
+			i++
+			hold, err := time.ParseDuration(args[i])
+			if err != nil {
+				log.Fatal(err)
+			}
+			options.hold = hold
+		case "-s":
+			options.scroll = true
+		case "-d":
+			options.drop = true
+		case "-r":
+			options.raw = true
+		default:
+			log.Fatalf(" Unknown tail option %s", arg)
+		}
+	}
+	return options
+}
+
+// tail shows every line of input on text, until the input ends. The
+// last line is left on the display.
+//
+func tail(text devices.TextDisplay, input io.Reader, options tailOptions) {
+	for line := range readLines(input, options.drop) {
+		line = cleanLine(line, options.raw)
+		if len(line) == 0 {
+			continue
+		}
+
+		if options.scroll || len([]rune(line)) > text.Width() {
+			text.Scroll(line)
+		} else {
+			text.Write(line)
+			time.Sleep(options.hold)
+		}
+	}
+}
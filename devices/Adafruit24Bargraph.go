@@ -0,0 +1,173 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "image"
+    "image/color"
+)
+
+// BARGRAPH_BARS is the number of bicolor bars on the bargraph.
+//
+const BARGRAPH_BARS int = 24
+
+// The Adafruit bicolor 24-bar bargraph backpack.
+//
+// The bars are wired as three rows of the HT16K33, each row a pair of
+// bytes in display RAM. The first byte of a pair drives red LEDs, the
+// second green, the opposite order to the bicolor matrix. Bars 0-11
+// use bits 0-3 of the rows, bars 12-23 bits 4-7.
+//
+// The bar mapping is originally from
+// https://github.com/adafruit/Adafruit_LED_Backpack/blob/master/Adafruit_LEDBackpack.cpp
+//
+type Adafruit24Bargraph struct {
+    name string
+    ht16k33 *HT16K33Driver
+    buffer []byte
+}
+
+func NewAdafruit24Bargraph(ht *HT16K33Driver) *Adafruit24Bargraph {
+    bargraph := &Adafruit24Bargraph {
+        name: "Adafruit24Bargraph",
+        ht16k33: ht,
+    }
+
+    // Three rows, each a red and a green byte.
+    //
+    bargraph.buffer = make([]byte, 6)
+
+    return bargraph
+}
+
+func (d *Adafruit24Bargraph) Name() string { return d.name }
+func (d *Adafruit24Bargraph) SetName(newName string ) { d.name = newName }
+func (d *Adafruit24Bargraph) HT16K33() *HT16K33Driver { return d.ht16k33 }
+
+// Returns the display RAM row and the bit within that row for a bar.
+//
+func barLocation(bar int) (row int, bit uint) {
+    if bar < 12 {
+        row = bar / 4
+    } else {
+        row = (bar - 12) / 4
+    }
+
+    bit = uint(bar % 4)
+    if bar >= 12 { bit += 4 }
+
+    return row, bit
+}
+
+// Sets the color of a single bar in the buffer. Call DrawBuffer
+// to display it.
+//
+func (d *Adafruit24Bargraph) SetBar(bar int, color LedColor) {
+    if bar < 0 || bar >= BARGRAPH_BARS {
+        return
+    }
+
+    row, bit := barLocation(bar)
+    d.buffer[row * 2] = setBit(d.buffer[row * 2], bit, color.red())
+    d.buffer[row * 2 + 1] = setBit(d.buffer[row * 2 + 1], bit, color.green())
+}
+
+// Returns the color of a single bar in the buffer.
+//
+func (d *Adafruit24Bargraph) Bar(bar int) LedColor {
+    if bar < 0 || bar >= BARGRAPH_BARS {
+        return LED_OFF
+    }
+
+    row, bit := barLocation(bar)
+    red := d.buffer[row * 2] & (1 << bit) != 0
+    green := d.buffer[row * 2 + 1] & (1 << bit) != 0
+    return ledColorOf(red, green)
+}
+
+// Shows a level from 0 to 24 as a meter. Bars below the level are lit,
+// green for the bottom half, yellow for the next quarter and red for
+// the top quarter. Bars at and above the level are turned off.
+// The bargraph is drawn immediately.
+//
+func (d *Adafruit24Bargraph) SetLevel(level int) {
+    for bar := 0 ; bar < BARGRAPH_BARS ; bar++ {
+        switch {
+        case bar >= level:
+            d.SetBar(bar, LED_OFF)
+        case bar < BARGRAPH_BARS / 2:
+            d.SetBar(bar, LED_GREEN)
+        case bar < BARGRAPH_BARS * 3 / 4:
+            d.SetBar(bar, LED_YELLOW)
+        default:
+            d.SetBar(bar, LED_RED)
+        }
+    }
+
+    d.DrawBuffer()
+}
+
+// Shows a value between min and max as a level on the meter.
+//
+func (d *Adafruit24Bargraph) SetMeter(value, min, max float64) {
+    if max <= min {
+        return
+    }
+
+    level := int((value - min) / (max - min) * float64(BARGRAPH_BARS) + 0.5)
+    if level < 0 { level = 0 }
+    if level > BARGRAPH_BARS { level = BARGRAPH_BARS }
+    d.SetLevel(level)
+}
+
+// A wrapper for WriteBlockData for displaying the buffer.
+//
+func (d *Adafruit24Bargraph) DrawBuffer() {
+    device := d.ht16k33.Connection()
+    if device != nil {
+        device.WriteBlockData(0, d.buffer)
+    }
+}
+
+// Turns off every bar, both in the buffer and on the display.
+//
+func (d *Adafruit24Bargraph) Clear() {
+    for i := range d.buffer {
+        d.buffer[i] = 0
+    }
+    d.DrawBuffer()
+}
+
+// Essentially a wrapper for i2c.Connection.Close()
+// with a call to clear the display first.
+// Call this last before exiting an application.
+//
+func (d *Adafruit24Bargraph) Close() {
+    d.Clear()
+    d.ht16k33.Close()
+}
+
+// Bounds, Set and Flush implement PixelDisplay, treating the
+// bargraph as a single row of 24 pixels.
+//
+func (d *Adafruit24Bargraph) Bounds() image.Rectangle { return image.Rect(0, 0, BARGRAPH_BARS, 1) }
+
+func (d *Adafruit24Bargraph) Set(x, y int, c color.Color) {
+    if y == 0 { d.SetBar(x, ToLedColor(c)) }
+}
+
+func (d *Adafruit24Bargraph) Flush() { d.DrawBuffer() }
@@ -345,9 +345,15 @@ func (d *Adafruit54AlphaDisplay) WriteDirect(message string) {
 
     if len(message) > digits { message = message[0:digits] }
 
+    // The neighbor on the left takes all but the last four characters,
+    // and this display is indexed from what is left.
+    //
     if d.neighborDisplay != nil {
         lim := len(message) - 4
-        if lim > 0 { d.neighborDisplay.WriteDirect(message[0:lim]) }
+        if lim > 0 {
+            d.neighborDisplay.WriteDirect(message[0:lim])
+            message = message[lim:]
+        }
     }
 
     var cindex uint8 = uint8(4 - len(message))
@@ -357,6 +363,47 @@ func (d *Adafruit54AlphaDisplay) WriteDirect(message string) {
     }
 }
 
+// ALPHA_DECIMAL_POINT is the segment bit for a digit's decimal point.
+//
+const ALPHA_DECIMAL_POINT uint16 = 0x4000
+
+// Writes text left justified across all chained displays, like Write,
+// and lights the decimal point of each location whose bit is set in
+// points, with location 0, the leftmost, in bit 0. A clock can light
+// a pair of them in place of a colon.
+//
+func (d *Adafruit54AlphaDisplay) WriteWithDecimals(text string, points uint32) {
+    letters := []rune(fitText(text, d.Width()))
+
+    if d.neighborDisplay != nil {
+        lim := len(letters) - 4
+        d.neighborDisplay.WriteWithDecimals(string(letters[:lim]), points)
+        letters = letters[lim:]
+        points >>= uint(lim)
+    }
+
+    for digit, letter := range letters {
+        val := alphaTable[string(letter)]
+        if points & (1 << uint(digit)) != 0 {
+            val |= ALPHA_DECIMAL_POINT
+        }
+        d.RawWriteDigit(uint8(digit), val)
+    }
+}
+
+// Width, Write and Scroll, along with Clear, implement TextDisplay.
+// Width is the number of digits across all chained displays.
+//
+func (d *Adafruit54AlphaDisplay) Width() int { return d.CountDeviceDigits() }
+
+func (d *Adafruit54AlphaDisplay) Write(text string) {
+    d.WriteWithDecimals(text, 0)
+}
+
+func (d *Adafruit54AlphaDisplay) Scroll(text string) {
+    d.ScrollString(text)
+}
+
 // Essentially a wrapper for i2c.Connection.Close()
 // with a call to clear the display first.
 // Call this last before exiting an application.
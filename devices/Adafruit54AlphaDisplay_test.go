@@ -0,0 +1,98 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "testing"
+)
+
+// Two alphanumeric displays chained, left and right, on simulated
+// HT16K33s, with the registers each has written since starting.
+//
+func startChainedAlpha(t *testing.T) (*Adafruit54AlphaDisplay, [2]*SimulatedConnection, [2]*[]uint8) {
+    var sims [2]*SimulatedConnection
+    var writes [2]*[]uint8
+    var alphas [2]*Adafruit54AlphaDisplay
+
+    for i := range sims {
+        sims[i] = NewSimulatedConnection()
+        driver := NewHT16K33Driver(0x70 + i)
+        if err := driver.StartWithConnection(sims[i]) ; err != nil {
+            t.Fatal(err)
+        }
+        writes[i] = recordWrites(sims[i])
+        alphas[i] = NewAdafruit54AlphaDisplay(driver)
+    }
+
+    alphas[1].SetNeighborDisplay(alphas[0])
+    return alphas[1], sims, writes
+}
+
+func digitValue(sim *SimulatedConnection, digit uint8) uint16 {
+    return uint16(sim.Register(digit * 2)) | uint16(sim.Register(digit * 2 + 1)) << 8
+}
+
+func checkDigits(t *testing.T, sims [2]*SimulatedConnection, want string) {
+    for i, letter := range want {
+        sim := sims[i / 4]
+        if got := digitValue(sim, uint8(i % 4)) ; got != alphaTable[string(letter)] {
+            t.Errorf("digit %d is %04x, not %q", i, got, letter)
+        }
+    }
+}
+
+func checkDigitRegisters(t *testing.T, writes [2]*[]uint8) {
+    for i, registers := range writes {
+        for _, reg := range *registers {
+            if reg > 0x07 {
+                t.Errorf("chip %d had register %02x written", i, reg)
+            }
+        }
+    }
+}
+
+func TestAlphaChainedWrite(t *testing.T) {
+    alpha, sims, writes := startChainedAlpha(t)
+
+    if alpha.Width() != 8 {
+        t.Errorf("a chained pair is %d wide, not 8", alpha.Width())
+    }
+
+    alpha.Write("HI")
+    checkDigits(t, sims, "HI      ")
+    checkDigitRegisters(t, writes)
+
+    alpha.Write("ABCDEFGHIJ")
+    checkDigits(t, sims, "ABCDEFGH")
+    checkDigitRegisters(t, writes)
+}
+
+func TestAlphaChainedWriteDirect(t *testing.T) {
+    alpha, sims, writes := startChainedAlpha(t)
+
+    // WriteDirect right justifies what it is given.
+    //
+    alpha.WriteDirect("ABCDEF")
+    checkDigits(t, sims, "  ABCDEF")
+    checkDigitRegisters(t, writes)
+
+    alpha.WriteDirect("XY")
+    if got := digitValue(sims[1], 2) ; got != alphaTable["X"] {
+        t.Errorf("digit 6 is %04x, not X", got)
+    }
+    checkDigitRegisters(t, writes)
+}
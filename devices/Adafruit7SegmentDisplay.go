@@ -0,0 +1,386 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// A map of ASCII characters in string format to bit maps to display
+// that character on a seven segment digit. Bit 0 is segment A, bit 6
+// is segment G. Bit 7, the decimal point, is handled separately.
+//
+// Mappings are originally from
+// https://github.com/adafruit/Adafruit_LED_Backpack/blob/master/Adafruit_LEDBackpack.cpp
+// and suitably modified for Go. Letters that can't be told apart
+// from digits on seven segments (such as S and 5) share a bit map.
+//
+var sevenSegmentTable = map[string]byte {
+    " ":0x00,
+    "\"":0x22,
+    "'":0x02,
+    "-":0x40,
+    "=":0x48,
+    "_":0x08,
+    "0":0x3F,
+    "1":0x06,
+    "2":0x5B,
+    "3":0x4F,
+    "4":0x66,
+    "5":0x6D,
+    "6":0x7D,
+    "7":0x07,
+    "8":0x7F,
+    "9":0x6F,
+    "?":0x53,
+    "A":0x77,
+    "B":0x7C,
+    "C":0x39,
+    "D":0x5E,
+    "E":0x79,
+    "F":0x71,
+    "G":0x3D,
+    "H":0x76,
+    "I":0x30,
+    "J":0x1E,
+    "L":0x38,
+    "N":0x54,
+    "O":0x3F,
+    "P":0x73,
+    "R":0x50,
+    "S":0x6D,
+    "T":0x78,
+    "U":0x3E,
+    "Y":0x6E,
+    "[":0x39,
+    "]":0x0F,
+    "a":0x5F,
+    "b":0x7C,
+    "c":0x58,
+    "d":0x5E,
+    "e":0x7B,
+    "f":0x71,
+    "g":0x6F,
+    "h":0x74,
+    "i":0x10,
+    "j":0x0E,
+    "l":0x30,
+    "n":0x54,
+    "o":0x5C,
+    "p":0x73,
+    "q":0x67,
+    "r":0x50,
+    "t":0x78,
+    "u":0x1C,
+    "y":0x6E,
+    "°":0x63,
+}
+
+// Bits that are not part of a digit's seven segments.
+//
+const (
+    SEVEN_SEGMENT_DECIMAL byte = 0x80
+    SEVEN_SEGMENT_COLON byte = 0x02
+)
+
+// The backpack wires its four digits to HT16K33 rows 0, 1, 3 and 4.
+// Row 2 drives the centre colon.
+//
+var sevenSegmentPosition = []uint8{0, 1, 3, 4}
+
+const sevenSegmentColonPosition uint8 = 2
+
+// SEVEN_SEGMENT_DIGITS is the number of digits on a backpack.
+//
+const SEVEN_SEGMENT_DIGITS int = 4
+
+type Adafruit7SegmentDisplay struct {
+    name string
+    ht16k33 *HT16K33Driver
+    digits [SEVEN_SEGMENT_DIGITS]byte
+    colon bool
+}
+
+func NewAdafruit7SegmentDisplay(ht *HT16K33Driver) *Adafruit7SegmentDisplay {
+    seven := &Adafruit7SegmentDisplay {
+        name: "Adafruit7SegmentDisplay",
+        ht16k33: ht,
+    }
+
+    return seven
+}
+
+func (d *Adafruit7SegmentDisplay) Name() string { return d.name }
+func (d *Adafruit7SegmentDisplay) SetName(newName string ) { d.name = newName }
+func (d *Adafruit7SegmentDisplay) HT16K33() *HT16K33Driver { return d.ht16k33 }
+func (d *Adafruit7SegmentDisplay) Colon() bool { return d.colon }
+
+// Write the segment bits, including the decimal point, to one of
+// the four digits. Digit 0 is on the left.
+//
+func (d *Adafruit7SegmentDisplay) RawWriteDigit(digit uint8, val byte) {
+    if int(digit) >= SEVEN_SEGMENT_DIGITS {
+        return
+    }
+
+    d.digits[digit] = val
+    display := d.ht16k33.Connection()
+    if display != nil {
+        display.WriteWordData(sevenSegmentPosition[digit] * 2, uint16(val))
+    }
+}
+
+// Turn the decimal point after a digit on or off, leaving the rest
+// of the digit as it is.
+//
+func (d *Adafruit7SegmentDisplay) SetDecimal(digit uint8, on bool) {
+    if int(digit) >= SEVEN_SEGMENT_DIGITS {
+        return
+    }
+
+    val := d.digits[digit] &^ SEVEN_SEGMENT_DECIMAL
+    if on { val |= SEVEN_SEGMENT_DECIMAL }
+    d.RawWriteDigit(digit, val)
+}
+
+// Turn the centre colon on or off.
+//
+func (d *Adafruit7SegmentDisplay) SetColon(on bool) {
+    d.colon = on
+
+    var val uint16
+    if on { val = uint16(SEVEN_SEGMENT_COLON) }
+
+    display := d.ht16k33.Connection()
+    if display != nil {
+        display.WriteWordData(sevenSegmentColonPosition * 2, val)
+    }
+}
+
+// Turn off all digits, decimal points and the colon.
+//
+func (d *Adafruit7SegmentDisplay) Clear() {
+    for digit := range d.digits {
+        d.RawWriteDigit(uint8(digit), 0)
+    }
+    d.SetColon(false)
+}
+
+// A basic function to display any hex number from 0 to F.
+//
+func (d *Adafruit7SegmentDisplay) DisplayNumber(digit uint8, val uint8) {
+    if val < 16 {
+        d.RawWriteDigit(digit, sevenSegmentTable[fmt.Sprintf("%X",val)])
+    }
+}
+
+// Writes a message right justified across the four digits.
+// A '.' lights the decimal point of the character before it,
+// and a ':' lights the colon, so "12:34" and "3.141" both fit.
+// Characters without a seven segment form are left blank.
+//
+func (d *Adafruit7SegmentDisplay) WriteDirect(message string) {
+    digits, _ := sevenSegmentDigits(message)
+    colon := strings.ContainsRune(message, ':')
+
+    if len(digits) > SEVEN_SEGMENT_DIGITS {
+        digits = digits[len(digits) - SEVEN_SEGMENT_DIGITS:]
+    }
+
+    blank := make([]byte, SEVEN_SEGMENT_DIGITS - len(digits))
+    d.writeDigits(append(blank, digits...), colon)
+}
+
+// Writes the segments of all four digits, and the colon.
+//
+func (d *Adafruit7SegmentDisplay) writeDigits(digits []byte, colon bool) {
+    for digit, val := range digits[:SEVEN_SEGMENT_DIGITS] {
+        d.RawWriteDigit(uint8(digit), val)
+    }
+    d.SetColon(colon)
+}
+
+// Parses a message into the segments of each digit it takes, with a
+// '.' folded into the digit before it, and whether a ':' follows each
+// digit.
+//
+func sevenSegmentDigits(message string) (digits []byte, colonAfter []bool) {
+    for _, letter := range message {
+        switch {
+        case letter == ':':
+            if len(digits) > 0 { colonAfter[len(digits)-1] = true }
+        case letter == '.' && len(digits) > 0 && digits[len(digits)-1] & SEVEN_SEGMENT_DECIMAL == 0:
+            digits[len(digits)-1] |= SEVEN_SEGMENT_DECIMAL
+        case letter == '.':
+            digits = append(digits, SEVEN_SEGMENT_DECIMAL)
+            colonAfter = append(colonAfter, false)
+        default:
+            val, ok := sevenSegmentTable[string(letter)]
+            if !ok {
+                val = sevenSegmentTable[strings.ToUpper(string(letter))]
+            }
+            digits = append(digits, val)
+            colonAfter = append(colonAfter, false)
+        }
+    }
+    return digits, colonAfter
+}
+
+// Display a decimal integer from -999 to 9999, right justified.
+//
+func (d *Adafruit7SegmentDisplay) WriteNumber(value int) error {
+    if value < -999 || value > 9999 {
+        d.WriteDirect("----")
+        return fmt.Errorf(" %d does not fit on %d digits", value, SEVEN_SEGMENT_DIGITS)
+    }
+
+    d.WriteDirect(strconv.Itoa(value))
+    return nil
+}
+
+// Display a floating point number with a fixed number of decimal places.
+// The number of places is reduced when the value would otherwise not fit.
+//
+func (d *Adafruit7SegmentDisplay) WriteFloat(value float64, places int) error {
+    if places < 0 { places = 0 }
+
+    for ; places >= 0 ; places-- {
+        text := strconv.FormatFloat(value, 'f', places, 64)
+        if len(text) - countDecimals(text) <= SEVEN_SEGMENT_DIGITS {
+            d.WriteDirect(text)
+            return nil
+        }
+    }
+
+    d.WriteDirect("----")
+    return fmt.Errorf(" %v does not fit on %d digits", value, SEVEN_SEGMENT_DIGITS)
+}
+
+func countDecimals(text string) (count int) {
+    for _, letter := range text {
+        if letter == '.' { count++ }
+    }
+    return count
+}
+
+// Display a 16-bit value as four hexadecimal digits.
+//
+func (d *Adafruit7SegmentDisplay) WriteHex(value uint16) {
+    for digit := 0 ; digit < SEVEN_SEGMENT_DIGITS ; digit++ {
+        shift := uint(12 - digit * 4)
+        d.DisplayNumber(uint8(digit), uint8(value >> shift) & 0x0F)
+    }
+}
+
+// Display hours and minutes in the style of a digital clock, with
+// a leading blank rather than a leading zero on the hours.
+// The colon is lit or not as asked, which callers can toggle every
+// second to get a blinking colon.
+//
+func (d *Adafruit7SegmentDisplay) WriteClock(hours, minutes int, colon bool) {
+    text := fmt.Sprintf("%2d%02d", hours % 100, minutes % 60)
+    d.WriteDirect(text)
+    d.SetColon(colon)
+}
+
+// Display the hours and minutes of a time, in 12 or 24 hour format.
+// The decimal point on the last digit is lit for PM in 12 hour format.
+//
+func (d *Adafruit7SegmentDisplay) WriteTime(t time.Time, twentyFourHour bool) {
+    hours := t.Hour()
+
+    if !twentyFourHour {
+        hours %= 12
+        if hours == 0 { hours = 12 }
+    }
+
+    d.WriteClock(hours, t.Minute(), true)
+
+    if !twentyFourHour && t.Hour() >= 12 {
+        d.SetDecimal(uint8(SEVEN_SEGMENT_DIGITS - 1), true)
+    }
+}
+
+// A test function to display hexademical numbers simultaniously
+// on all digits, along with the decimal points and colon.
+//
+func (d *Adafruit7SegmentDisplay) NumbersTest() {
+    d.Clear()
+    var i uint8
+    for i = 0 ; i < 16 ; i++ {
+        for digit := 0 ; digit < SEVEN_SEGMENT_DIGITS ; digit++ {
+            d.DisplayNumber(uint8(digit), i)
+            d.SetDecimal(uint8(digit), i & 1 == 1)
+        }
+        d.SetColon(i & 1 == 0)
+        time.Sleep(500 * time.Millisecond)
+    }
+    d.Clear()
+}
+
+// Width, Write and Scroll, along with Clear, implement TextDisplay.
+//
+func (d *Adafruit7SegmentDisplay) Width() int { return SEVEN_SEGMENT_DIGITS }
+
+func (d *Adafruit7SegmentDisplay) Write(text string) {
+    d.WriteDirect(text)
+}
+
+// Scroll an alphnumeric string across the digits, one digit at a
+// time. Characters without a seven segment form scroll past as blanks.
+//
+func (d *Adafruit7SegmentDisplay) Scroll(text string) {
+    digits, colons := sevenSegmentScroll(text)
+
+    for i := range digits {
+        d.writeDigits(digits[i], colons[i])
+        time.Sleep(400 * time.Millisecond)
+    }
+
+    d.Clear()
+}
+
+// Returns each step of a scroll: the four digits shown, and whether the
+// colon is lit, which it is when a ':' falls between the middle two.
+// The windows are built from parsed digits, so that a '.' rides along
+// on its digit rather than taking a step of its own.
+//
+func sevenSegmentScroll(text string) (windows [][]byte, colons []bool) {
+    digits, colonAfter := sevenSegmentDigits(text)
+
+    blank := make([]byte, SEVEN_SEGMENT_DIGITS)
+    padded := append(append(append([]byte{}, blank...), digits...), blank...)
+    paddedColons := append(append(make([]bool, SEVEN_SEGMENT_DIGITS), colonAfter...), make([]bool, SEVEN_SEGMENT_DIGITS)...)
+
+    for start := 1 ; start + SEVEN_SEGMENT_DIGITS <= len(padded) ; start++ {
+        windows = append(windows, padded[start:start + SEVEN_SEGMENT_DIGITS])
+        colons = append(colons, paddedColons[start + 1])
+    }
+    return windows, colons
+}
+
+// Essentially a wrapper for i2c.Connection.Close()
+// with a call to clear the display first.
+// Call this last before exiting an application.
+//
+func (d *Adafruit7SegmentDisplay) Close() {
+    d.Clear()
+    d.ht16k33.Close()
+}
@@ -0,0 +1,95 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "bytes"
+    "testing"
+)
+
+func segments(text string) []byte {
+    digits, _ := sevenSegmentDigits(text)
+    return digits
+}
+
+func TestSevenSegmentScrollWindows(t *testing.T) {
+    windows, colons := sevenSegmentScroll("3.14")
+
+    // Three digits scroll in and out over six steps, the 3 carrying its
+    // decimal point, with no step spent on the '.' alone.
+    //
+    three := sevenSegmentTable["3"] | SEVEN_SEGMENT_DECIMAL
+    want := [][]byte {
+        { 0, 0, 0, three },
+        append([]byte{ 0, 0, three }, segments("1")...),
+        append([]byte{ 0, three }, segments("14")...),
+        append([]byte{ three }, append(segments("14"), 0)...),
+        append(segments("14"), 0, 0),
+        append(segments("4"), 0, 0, 0),
+        { 0, 0, 0, 0 },
+    }
+
+    if len(windows) != len(want) {
+        t.Fatalf("%d steps, not %d: % x", len(windows), len(want), windows)
+    }
+    for i := range want {
+        if !bytes.Equal(windows[i], want[i]) {
+            t.Errorf("step %d is % x, not % x", i, windows[i], want[i])
+        }
+        if colons[i] {
+            t.Errorf("step %d lights the colon", i)
+        }
+    }
+}
+
+func TestSevenSegmentScrollColon(t *testing.T) {
+    windows, colons := sevenSegmentScroll("12:34")
+
+    // The colon is lit only while it sits between the middle digits.
+    //
+    for i := range windows {
+        want := bytes.Equal(windows[i], segments("1234"))
+        if colons[i] != want {
+            t.Errorf("step %d, % x, has the colon %v", i, windows[i], colons[i])
+        }
+    }
+    if len(windows) != 8 {
+        t.Errorf("%d steps, not 8", len(windows))
+    }
+}
+
+func TestSevenSegmentWriteDirect(t *testing.T) {
+    sim := NewSimulatedConnection()
+    driver := NewHT16K33Driver(0x70)
+    if err := driver.StartWithConnection(sim) ; err != nil {
+        t.Fatal(err)
+    }
+    seven := NewAdafruit7SegmentDisplay(driver)
+
+    seven.WriteDirect("3.14")
+    want := append([]byte{ 0, sevenSegmentTable["3"] | SEVEN_SEGMENT_DECIMAL }, segments("14")...)
+    for digit, val := range want {
+        if got := sim.Register(sevenSegmentPosition[digit] * 2) ; got != val {
+            t.Errorf("digit %d is %02x, not %02x", digit, got, val)
+        }
+    }
+
+    seven.WriteDirect("12:34")
+    if !seven.Colon() || sim.Register(sevenSegmentColonPosition * 2) != SEVEN_SEGMENT_COLON {
+        t.Error("12:34 left the colon off")
+    }
+}
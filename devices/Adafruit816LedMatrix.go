@@ -16,6 +16,11 @@ limitations under the License.
 
 package devices
 
+import (
+    "image"
+    "image/color"
+)
+
 type Adafruit816LedMatrix struct {
     name string
     ht16k33 *HT16K33Driver
@@ -59,6 +64,41 @@ func (d *Adafruit816LedMatrix) LoadBuffer(bits []byte, block int) {
     }
 }
 
+// Loads a VT52 character into one of the two blocks.
+//
+func (d *Adafruit816LedMatrix) LoadCharacter(char int, block int) {
+    d.LoadBuffer(GetVT52Character(char), block)
+}
+
+// Turns a single LED in the buffer on or off. Column 0 is on the
+// left of block 0 and row 0 at the top. Call DrawBuffer to display it.
+//
+func (d *Adafruit816LedMatrix) SetPixel(x, y int, on bool) {
+    if x < 0 || x >= len(d.buffer) || y < 0 || y > 7 {
+        return
+    }
+
+    d.buffer[d.altIndex[x]] = setBit(d.buffer[d.altIndex[x]], uint(7 - y), on)
+}
+
+// Returns whether a single LED in the buffer is on.
+//
+func (d *Adafruit816LedMatrix) Pixel(x, y int) bool {
+    if x < 0 || x >= len(d.buffer) || y < 0 || y > 7 {
+        return false
+    }
+
+    return d.buffer[d.altIndex[x]] & (0x80 >> uint(y)) != 0
+}
+
+// Turns every LED in the buffer off.
+//
+func (d *Adafruit816LedMatrix) ClearBuffer() {
+    for i := range d.buffer {
+        d.buffer[i] = 0
+    }
+}
+
 // A wrapper for WriteBlockData for displaying the buffer.
 //
 func (d *Adafruit816LedMatrix) DrawBuffer() {
@@ -80,3 +120,44 @@ func (d *Adafruit816LedMatrix) RotateBuffer() {
     d.buffer[0] = end
 }
 
+
+// Turns off every LED, both in the buffer and on the display.
+//
+func (d *Adafruit816LedMatrix) Clear() {
+    d.ClearBuffer()
+    d.DrawBuffer()
+}
+
+// Essentially a wrapper for i2c.Connection.Close()
+// with a call to clear the display first.
+// Call this last before exiting an application.
+//
+func (d *Adafruit816LedMatrix) Close() {
+    d.Clear()
+    d.ht16k33.Close()
+}
+
+// Width, Write and Scroll, along with Clear, implement TextDisplay
+// with the VT52 font, one character on each block.
+//
+func (d *Adafruit816LedMatrix) Width() int { return 2 }
+
+func (d *Adafruit816LedMatrix) Write(text string) {
+    for block, char := range fitText(text, d.Width()) {
+        d.LoadCharacter(int(char), block)
+    }
+    d.DrawBuffer()
+}
+
+func (d *Adafruit816LedMatrix) Scroll(text string) {
+    scrollMatrix(text, len(d.buffer), func(columns []byte) {
+        d.LoadBuffer(columns, 0)
+        d.DrawBuffer()
+    })
+}
+
+// Bounds, Set and Flush implement PixelDisplay.
+//
+func (d *Adafruit816LedMatrix) Bounds() image.Rectangle { return image.Rect(0, 0, len(d.buffer), 8) }
+func (d *Adafruit816LedMatrix) Set(x, y int, c color.Color) { d.SetPixel(x, y, lit(c)) }
+func (d *Adafruit816LedMatrix) Flush() { d.DrawBuffer() }
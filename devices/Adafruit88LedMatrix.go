@@ -0,0 +1,190 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "image"
+    "image/color"
+)
+
+// How the LEDs of an 8x8 matrix are wired to the HT16K33.
+// The matrix is always addressed with column 0 on the left and row 0
+// at the top; the wiring says where that LED lives in display RAM.
+//
+// Without Transpose each matrix row is a row of the HT16K33 (a byte
+// pair in display RAM, only the first byte used) and each column a bit
+// within it. With Transpose the roles are swapped. ColumnOffset rotates
+// the bit position, as the backpacks wire bit 0 to the last column.
+//
+type Matrix88Wiring struct {
+    Transpose bool
+    ColumnOffset int
+}
+
+// Wiring of the Adafruit mini 0.8" 8x8 matrix backpacks.
+//
+var MATRIX_88_MINI = Matrix88Wiring { Transpose: false, ColumnOffset: 7 }
+
+// Wiring of the Adafruit 1.2" 8x8 matrix backpacks, where the
+// larger matrices are soldered with rows and columns swapped.
+//
+var MATRIX_88_LARGE = Matrix88Wiring { Transpose: true, ColumnOffset: 7 }
+
+// A single color 8x8 LED matrix on an HT16K33 backpack.
+//
+// The buffer is kept in the same column format as the 8x16 matrix and
+// the VT52 font, one byte per column with the most significant bit at
+// the top, and is translated to the backpack's wiring when drawn.
+//
+type Adafruit88LedMatrix struct {
+    name string
+    ht16k33 *HT16K33Driver
+    wiring Matrix88Wiring
+    buffer []byte
+}
+
+func NewAdafruit88LedMatrix(ht *HT16K33Driver, wiring Matrix88Wiring) *Adafruit88LedMatrix {
+    matrix := &Adafruit88LedMatrix {
+        name: "Adafruit88LedMatrix",
+        ht16k33: ht,
+        wiring: wiring,
+    }
+
+    matrix.buffer = make([]byte, 8)
+
+    return matrix
+}
+
+func (d *Adafruit88LedMatrix) Name() string { return d.name }
+func (d *Adafruit88LedMatrix) SetName(newName string ) { d.name = newName }
+func (d *Adafruit88LedMatrix) HT16K33() *HT16K33Driver { return d.ht16k33 }
+func (d *Adafruit88LedMatrix) Wiring() Matrix88Wiring { return d.wiring }
+
+// Loads the buffer with up to eight columns of data.
+//
+func (d *Adafruit88LedMatrix) LoadBuffer(bits []byte) {
+    copy(d.buffer, bits)
+}
+
+// Loads a VT52 character into the buffer.
+//
+func (d *Adafruit88LedMatrix) LoadCharacter(char int) {
+    d.LoadBuffer(GetVT52Character(char))
+}
+
+// Turns a single LED in the buffer on or off. Column 0 is on the
+// left and row 0 at the top. Call DrawBuffer to display it.
+//
+func (d *Adafruit88LedMatrix) SetPixel(x, y int, on bool) {
+    if x < 0 || x > 7 || y < 0 || y > 7 {
+        return
+    }
+
+    d.buffer[x] = setBit(d.buffer[x], uint(7 - y), on)
+}
+
+// Returns whether a single LED in the buffer is on.
+//
+func (d *Adafruit88LedMatrix) Pixel(x, y int) bool {
+    if x < 0 || x > 7 || y < 0 || y > 7 {
+        return false
+    }
+
+    return d.buffer[x] & (0x80 >> uint(y)) != 0
+}
+
+// Turns every LED in the buffer off.
+//
+func (d *Adafruit88LedMatrix) ClearBuffer() {
+    for i := range d.buffer {
+        d.buffer[i] = 0
+    }
+}
+
+// Rotates the buffer contents from left to right.
+//
+func (d *Adafruit88LedMatrix) RotateBuffer() {
+    end := d.buffer[len(d.buffer) - 1]
+    copy(d.buffer[1:], d.buffer[:len(d.buffer) - 1])
+    d.buffer[0] = end
+}
+
+// Translates the buffer to the backpack's wiring and writes it out.
+//
+func (d *Adafruit88LedMatrix) DrawBuffer() {
+    ram := make([]byte, 16)
+
+    for x := 0 ; x < 8 ; x++ {
+        for y := 0 ; y < 8 ; y++ {
+            if !d.Pixel(x, y) {
+                continue
+            }
+
+            row, column := y, x
+            if d.wiring.Transpose {
+                row, column = x, y
+            }
+
+            bit := uint((column + d.wiring.ColumnOffset) % 8)
+            ram[row * 2] |= 1 << bit
+        }
+    }
+
+    device := d.ht16k33.Connection()
+    if device != nil {
+        device.WriteBlockData(0, ram)
+    }
+}
+
+// Turns off every LED, both in the buffer and on the display.
+//
+func (d *Adafruit88LedMatrix) Clear() {
+    d.ClearBuffer()
+    d.DrawBuffer()
+}
+
+// Essentially a wrapper for i2c.Connection.Close()
+// with a call to clear the display first.
+// Call this last before exiting an application.
+//
+func (d *Adafruit88LedMatrix) Close() {
+    d.Clear()
+    d.ht16k33.Close()
+}
+
+// Width, Write and Scroll, along with Clear, implement TextDisplay
+// with the VT52 font, one character at a time.
+//
+func (d *Adafruit88LedMatrix) Width() int { return 1 }
+
+func (d *Adafruit88LedMatrix) Write(text string) {
+    d.LoadCharacter(int([]rune(fitText(text, d.Width()))[0]))
+    d.DrawBuffer()
+}
+
+func (d *Adafruit88LedMatrix) Scroll(text string) {
+    scrollMatrix(text, len(d.buffer), func(columns []byte) {
+        d.LoadBuffer(columns)
+        d.DrawBuffer()
+    })
+}
+
+// Bounds, Set and Flush implement PixelDisplay.
+//
+func (d *Adafruit88LedMatrix) Bounds() image.Rectangle { return image.Rect(0, 0, 8, 8) }
+func (d *Adafruit88LedMatrix) Set(x, y int, c color.Color) { d.SetPixel(x, y, lit(c)) }
+func (d *Adafruit88LedMatrix) Flush() { d.DrawBuffer() }
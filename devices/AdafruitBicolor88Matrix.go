@@ -0,0 +1,155 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "image"
+    "image/color"
+)
+
+// The Adafruit bicolor 8x8 LED matrix backpack.
+//
+// Each of the eight rows uses a pair of bytes in the HT16K33's display
+// RAM. The first byte of the pair drives the green LEDs of that row,
+// the second byte the red LEDs, with bit x for column x. Lighting both
+// makes yellow.
+//
+type AdafruitBicolor88Matrix struct {
+    name string
+    ht16k33 *HT16K33Driver
+    buffer []byte
+    textColor LedColor
+}
+
+func NewAdafruitBicolor88Matrix(ht *HT16K33Driver) *AdafruitBicolor88Matrix {
+    matrix := &AdafruitBicolor88Matrix {
+        name: "AdafruitBicolor88Matrix",
+        ht16k33: ht,
+        textColor: LED_GREEN,
+    }
+
+    // Matches the HT16K33's internal data buffer.
+    //
+    matrix.buffer = make([]byte, 16)
+
+    return matrix
+}
+
+func (d *AdafruitBicolor88Matrix) Name() string { return d.name }
+func (d *AdafruitBicolor88Matrix) SetName(newName string ) { d.name = newName }
+func (d *AdafruitBicolor88Matrix) HT16K33() *HT16K33Driver { return d.ht16k33 }
+func (d *AdafruitBicolor88Matrix) TextColor() LedColor { return d.textColor }
+func (d *AdafruitBicolor88Matrix) SetTextColor(color LedColor) { d.textColor = color }
+
+// Sets the color of a single LED in the buffer. Column 0 is on the
+// left and row 0 at the top. Call DrawBuffer to display it.
+//
+func (d *AdafruitBicolor88Matrix) SetPixel(x, y int, color LedColor) {
+    if x < 0 || x > 7 || y < 0 || y > 7 {
+        return
+    }
+
+    d.buffer[y * 2] = setBit(d.buffer[y * 2], uint(x), color.green())
+    d.buffer[y * 2 + 1] = setBit(d.buffer[y * 2 + 1], uint(x), color.red())
+}
+
+// Returns the color of a single LED in the buffer.
+//
+func (d *AdafruitBicolor88Matrix) Pixel(x, y int) LedColor {
+    if x < 0 || x > 7 || y < 0 || y > 7 {
+        return LED_OFF
+    }
+
+    green := d.buffer[y * 2] & (1 << uint(x)) != 0
+    red := d.buffer[y * 2 + 1] & (1 << uint(x)) != 0
+    return ledColorOf(red, green)
+}
+
+// Sets every LED in the buffer to the same color.
+//
+func (d *AdafruitBicolor88Matrix) Fill(color LedColor) {
+    for y := 0 ; y < 8 ; y++ {
+        for x := 0 ; x < 8 ; x++ {
+            d.SetPixel(x, y, color)
+        }
+    }
+}
+
+// Loads a glyph into the buffer in a single color. The glyph is in the
+// same column format as the 8x16 matrix and the VT52 font, one byte
+// per column with the most significant bit at the top. Unlit LEDs are
+// turned off.
+//
+func (d *AdafruitBicolor88Matrix) LoadBuffer(bits []byte, color LedColor) {
+    for x := 0 ; x < len(bits) && x < 8 ; x++ {
+        for y := 0 ; y < 8 ; y++ {
+            if bits[x] & (0x80 >> uint(y)) != 0 {
+                d.SetPixel(x, y, color)
+            } else {
+                d.SetPixel(x, y, LED_OFF)
+            }
+        }
+    }
+}
+
+// A wrapper for WriteBlockData for displaying the buffer.
+//
+func (d *AdafruitBicolor88Matrix) DrawBuffer() {
+    device := d.ht16k33.Connection()
+    if device != nil {
+        device.WriteBlockData(0, d.buffer)
+    }
+}
+
+// Turns off every LED, both in the buffer and on the display.
+//
+func (d *AdafruitBicolor88Matrix) Clear() {
+    d.Fill(LED_OFF)
+    d.DrawBuffer()
+}
+
+// Essentially a wrapper for i2c.Connection.Close()
+// with a call to clear the display first.
+// Call this last before exiting an application.
+//
+func (d *AdafruitBicolor88Matrix) Close() {
+    d.Clear()
+    d.ht16k33.Close()
+}
+
+// Width, Write and Scroll, along with Clear, implement TextDisplay
+// with the VT52 font, one character at a time, in the text color.
+//
+func (d *AdafruitBicolor88Matrix) Width() int { return 1 }
+
+func (d *AdafruitBicolor88Matrix) Write(text string) {
+    d.LoadBuffer(GetVT52Character(int([]rune(fitText(text, d.Width()))[0])), d.textColor)
+    d.DrawBuffer()
+}
+
+func (d *AdafruitBicolor88Matrix) Scroll(text string) {
+    scrollMatrix(text, 8, func(columns []byte) {
+        d.LoadBuffer(columns, d.textColor)
+        d.DrawBuffer()
+    })
+}
+
+// Bounds, Set and Flush implement PixelDisplay.
+//
+func (d *AdafruitBicolor88Matrix) Bounds() image.Rectangle { return image.Rect(0, 0, 8, 8) }
+func (d *AdafruitBicolor88Matrix) Set(x, y int, c color.Color) { d.SetPixel(x, y, ToLedColor(c)) }
+func (d *AdafruitBicolor88Matrix) Flush() { d.DrawBuffer() }
@@ -0,0 +1,619 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "fmt"
+    "sync"
+    "time"
+
+    "gobot.io/x/gobot/drivers/i2c"
+)
+
+// The BNO055's I2C addresses, selected by the COM3 pin. Adafruit's
+// breakout pulls it low for 0x28; bridging ADR selects 0x29.
+//
+const (
+    BNO055_DEFAULT_ADDRESS int = 0x28
+    BNO055_ALTERNATE_ADDRESS int = 0x29
+)
+
+// BNO055_ID is the value of the CHIP_ID register.
+//
+const BNO055_ID byte = 0xA0
+
+// BNO055 page 0 register addresses, from section 4.2 of the datasheet
+// in the datasheets folder. Multi-byte values are little endian and
+// start at the address given.
+//
+const (
+    BNO055_CHIP_ID byte = 0x00
+    BNO055_ACC_ID byte = 0x01
+    BNO055_MAG_ID byte = 0x02
+    BNO055_GYR_ID byte = 0x03
+    BNO055_SW_REV_ID byte = 0x04
+    BNO055_BL_REV_ID byte = 0x06
+    BNO055_PAGE_ID byte = 0x07
+    BNO055_ACC_DATA byte = 0x08
+    BNO055_MAG_DATA byte = 0x0E
+    BNO055_GYR_DATA byte = 0x14
+    BNO055_EUL_DATA byte = 0x1A
+    BNO055_QUA_DATA byte = 0x20
+    BNO055_LIA_DATA byte = 0x28
+    BNO055_GRV_DATA byte = 0x2E
+    BNO055_TEMP byte = 0x34
+    BNO055_CALIB_STAT byte = 0x35
+    BNO055_ST_RESULT byte = 0x36
+    BNO055_SYS_STATUS byte = 0x39
+    BNO055_SYS_ERR byte = 0x3A
+    BNO055_UNIT_SEL byte = 0x3B
+    BNO055_OPR_MODE byte = 0x3D
+    BNO055_PWR_MODE byte = 0x3E
+    BNO055_SYS_TRIGGER byte = 0x3F
+    BNO055_OFFSETS byte = 0x55
+)
+
+// Bits in SYS_TRIGGER.
+//
+const (
+    BNO055_TRIGGER_RESET byte = 0x20
+    BNO055_TRIGGER_SELF_TEST byte = 0x01
+)
+
+// BNO055_UNITS is the UNIT_SEL value the driver expects: acceleration
+// in m/s², angular rate in degrees per second, angles in degrees,
+// temperature in Celsius, and Android orientation, where pitch runs
+// from +180 to -180 and roll from -90 to +90. It is the power-on value.
+//
+const BNO055_UNITS byte = 0x80
+
+// BNO055_OFFSETS_LENGTH is the number of calibration offset bytes,
+// accelerometer, magnetometer and gyroscope offsets then the
+// accelerometer and magnetometer radii.
+//
+const BNO055_OFFSETS_LENGTH int = 22
+
+// An operation mode, selecting which sensors run and whether their
+// readings are fused into an orientation.
+//
+type BNO055Mode byte
+
+const (
+    BNO055_MODE_CONFIG BNO055Mode = 0x00
+    BNO055_MODE_ACCONLY BNO055Mode = 0x01
+    BNO055_MODE_MAGONLY BNO055Mode = 0x02
+    BNO055_MODE_GYRONLY BNO055Mode = 0x03
+    BNO055_MODE_ACCMAG BNO055Mode = 0x04
+    BNO055_MODE_ACCGYRO BNO055Mode = 0x05
+    BNO055_MODE_MAGGYRO BNO055Mode = 0x06
+    BNO055_MODE_AMG BNO055Mode = 0x07
+    BNO055_MODE_IMU BNO055Mode = 0x08
+    BNO055_MODE_COMPASS BNO055Mode = 0x09
+    BNO055_MODE_M4G BNO055Mode = 0x0A
+    BNO055_MODE_NDOF_FMC_OFF BNO055Mode = 0x0B
+    BNO055_MODE_NDOF BNO055Mode = 0x0C
+)
+
+var bno055ModeNames = []string {
+    "CONFIG", "ACCONLY", "MAGONLY", "GYRONLY", "ACCMAG", "ACCGYRO", "MAGGYRO",
+    "AMG", "IMU", "COMPASS", "M4G", "NDOF_FMC_OFF", "NDOF",
+}
+
+func (m BNO055Mode) String() string {
+    if int(m) < len(bno055ModeNames) {
+        return bno055ModeNames[m]
+    }
+    return fmt.Sprintf("mode 0x%02x", byte(m))
+}
+
+// Whether the mode fuses the sensors into an orientation. Only fusion
+// modes have Euler angles, quaternions, linear acceleration and gravity.
+//
+func (m BNO055Mode) Fusion() bool {
+    return m >= BNO055_MODE_IMU && m <= BNO055_MODE_NDOF
+}
+
+// How long the BNO055 takes to switch modes, and to boot after a reset.
+//
+const (
+    bno055FromConfigTime = 7 * time.Millisecond
+    bno055ToConfigTime = 19 * time.Millisecond
+    bno055BootTime = 650 * time.Millisecond
+)
+
+// Orientation as Euler angles, in degrees.
+//
+type Euler struct {
+    Heading float64
+    Roll float64
+    Pitch float64
+}
+
+// Orientation as a unit quaternion.
+//
+type Quaternion struct {
+    W float64
+    X float64
+    Y float64
+    Z float64
+}
+
+// A reading along the three axes, in m/s² for accelerations.
+//
+type Vector struct {
+    X float64
+    Y float64
+    Z float64
+}
+
+// The calibration level of the fusion algorithm and each sensor, from
+// 0, uncalibrated, to 3, fully calibrated.
+//
+type BNO055Calibration struct {
+    System int
+    Gyroscope int
+    Accelerometer int
+    Magnetometer int
+}
+
+func (c BNO055Calibration) String() string {
+    return fmt.Sprintf("S%d G%d A%d M%d", c.System, c.Gyroscope, c.Accelerometer, c.Magnetometer)
+}
+
+// Whether every level is 3.
+//
+func (c BNO055Calibration) Calibrated() bool {
+    return c.System == 3 && c.Gyroscope == 3 && c.Accelerometer == 3 && c.Magnetometer == 3
+}
+
+// The calibration offsets, as stored from BNO055_OFFSETS.
+//
+type BNO055Offsets [BNO055_OFFSETS_LENGTH]byte
+
+// A driver for the Bosch BNO055 9-axis absolute orientation sensor, as
+// on the Adafruit BNO055 breakout.
+//
+type BNO055Driver struct {
+    name string
+    address int
+    connection i2c.Connection
+    mutex sync.Mutex
+    mode BNO055Mode
+}
+
+func NewBNO055Driver(addr int) *BNO055Driver {
+    driver := &BNO055Driver {
+        name: "BNO055",
+        address: addr,
+    }
+
+    return driver
+}
+
+func (d *BNO055Driver) Name() string { return d.name }
+func (d *BNO055Driver) SetName(newName string ) { d.name = newName }
+func (d *BNO055Driver) Address() int { return d.address }
+func (d *BNO055Driver) Connection() i2c.Connection { return d.connection }
+func (d *BNO055Driver) Mode() BNO055Mode { return d.mode }
+
+// Initializes and opens a connection to a BNO055 on the default bus,
+// and starts it in NDOF mode.
+//
+func (d *BNO055Driver) Start() (err error) {
+    connection, err := openI2CConnection(d.address)
+    if err != nil {
+        return err
+    }
+
+    return d.StartWithConnection(connection)
+}
+
+// Initializes the driver over a connection that has already been
+// opened, such as a SimulatedBNO055. The chip ID is checked, the
+// units set, and the BNO055 put in NDOF mode.
+//
+func (d *BNO055Driver) StartWithConnection(connection i2c.Connection) (err error) {
+    d.connection = connection
+
+    // The BNO055 doesn't answer until it has booted, which takes a
+    // while after power up.
+    //
+    id, err := d.connection.ReadByteData(BNO055_CHIP_ID)
+    if err != nil || id != BNO055_ID {
+        time.Sleep(bno055BootTime)
+        if id, err = d.connection.ReadByteData(BNO055_CHIP_ID) ; err != nil {
+            return err
+        }
+    }
+    if id != BNO055_ID {
+        return fmt.Errorf(" Chip ID 0x%02x is not a BNO055's 0x%02x", id, BNO055_ID)
+    }
+
+    steps := []struct{ reg, val byte } {
+        { BNO055_OPR_MODE, byte(BNO055_MODE_CONFIG) },
+        { BNO055_PAGE_ID, 0 },
+        { BNO055_PWR_MODE, 0 },
+        { BNO055_UNIT_SEL, BNO055_UNITS },
+    }
+
+    for _, step := range steps {
+        if err = d.connection.WriteByteData(step.reg, step.val) ; err != nil {
+            return err
+        }
+    }
+    time.Sleep(bno055ToConfigTime)
+    d.mode = BNO055_MODE_CONFIG
+
+    return d.SetMode(BNO055_MODE_NDOF)
+}
+
+// Resets the BNO055, waits for it to boot, and puts it back in the
+// current mode. Calibration is lost.
+//
+func (d *BNO055Driver) Reset() error {
+    if d.connection == nil {
+        return fmt.Errorf(" %s is not started", d.name)
+    }
+
+    mode := d.mode
+    if err := d.connection.WriteByteData(BNO055_SYS_TRIGGER, BNO055_TRIGGER_RESET) ; err != nil {
+        return err
+    }
+    time.Sleep(bno055BootTime)
+
+    if err := d.StartWithConnection(d.connection) ; err != nil {
+        return err
+    }
+    return d.SetMode(mode)
+}
+
+// Reads the chip's ID registers: the BNO055 itself, then its
+// accelerometer, magnetometer and gyroscope.
+//
+func (d *BNO055Driver) ChipIDs() (chip, accelerometer, magnetometer, gyroscope byte, err error) {
+    ids, err := d.readRegisters(BNO055_CHIP_ID, 4)
+    if err != nil {
+        return 0, 0, 0, 0, err
+    }
+    return ids[0], ids[1], ids[2], ids[3], nil
+}
+
+// Switches operation mode. The BNO055 can only go between two modes
+// through CONFIG, which is done here.
+//
+func (d *BNO055Driver) SetMode(mode BNO055Mode) error {
+    if d.connection == nil {
+        return fmt.Errorf(" %s is not started", d.name)
+    }
+    if mode > BNO055_MODE_NDOF {
+        return fmt.Errorf(" %s is not a BNO055 mode", mode)
+    }
+
+    d.mutex.Lock()
+    defer d.mutex.Unlock()
+
+    return d.setMode(mode)
+}
+
+// Switches mode with the mutex held.
+//
+func (d *BNO055Driver) setMode(mode BNO055Mode) error {
+    if mode == d.mode {
+        return nil
+    }
+
+    if d.mode != BNO055_MODE_CONFIG {
+        if err := d.connection.WriteByteData(BNO055_OPR_MODE, byte(BNO055_MODE_CONFIG)) ; err != nil {
+            return err
+        }
+        time.Sleep(bno055ToConfigTime)
+        d.mode = BNO055_MODE_CONFIG
+    }
+
+    if mode != BNO055_MODE_CONFIG {
+        if err := d.connection.WriteByteData(BNO055_OPR_MODE, byte(mode)) ; err != nil {
+            return err
+        }
+        time.Sleep(bno055FromConfigTime)
+        d.mode = mode
+    }
+
+    return nil
+}
+
+// Reads consecutive registers in one transfer, so that the bytes of a
+// reading all come from the same sample.
+//
+func (d *BNO055Driver) readRegisters(reg byte, count int) ([]byte, error) {
+    if d.connection == nil {
+        return nil, fmt.Errorf(" %s is not started", d.name)
+    }
+
+    if _, err := d.connection.Write([]byte{ reg }) ; err != nil {
+        return nil, err
+    }
+
+    data := make([]byte, count)
+    if _, err := d.connection.Read(data) ; err != nil {
+        return nil, err
+    }
+    return data, nil
+}
+
+// Reads count little endian signed 16-bit values, each divided by scale.
+//
+func (d *BNO055Driver) readScaled(reg byte, count int, scale float64) ([]float64, error) {
+    data, err := d.readRegisters(reg, count * 2)
+    if err != nil {
+        return nil, err
+    }
+
+    values := make([]float64, count)
+    for i := range values {
+        values[i] = float64(int16(uint16(data[2 * i]) | uint16(data[2 * i + 1]) << 8)) / scale
+    }
+    return values, nil
+}
+
+func (d *BNO055Driver) requireFusion() error {
+    if !d.mode.Fusion() {
+        return fmt.Errorf(" %s has no fusion data in %s mode", d.name, d.mode)
+    }
+    return nil
+}
+
+// Reads the orientation as Euler angles, in degrees. Needs a fusion mode.
+//
+func (d *BNO055Driver) Euler() (Euler, error) {
+    if err := d.requireFusion() ; err != nil {
+        return Euler{}, err
+    }
+
+    values, err := d.readScaled(BNO055_EUL_DATA, 3, 16)
+    if err != nil {
+        return Euler{}, err
+    }
+    return Euler { Heading: values[0], Roll: values[1], Pitch: values[2] }, nil
+}
+
+// Reads the orientation as a unit quaternion. Needs a fusion mode.
+//
+func (d *BNO055Driver) Quaternion() (Quaternion, error) {
+    if err := d.requireFusion() ; err != nil {
+        return Quaternion{}, err
+    }
+
+    values, err := d.readScaled(BNO055_QUA_DATA, 4, 1 << 14)
+    if err != nil {
+        return Quaternion{}, err
+    }
+    return Quaternion { W: values[0], X: values[1], Y: values[2], Z: values[3] }, nil
+}
+
+func (d *BNO055Driver) readVector(reg byte) (Vector, error) {
+    if err := d.requireFusion() ; err != nil {
+        return Vector{}, err
+    }
+
+    values, err := d.readScaled(reg, 3, 100)
+    if err != nil {
+        return Vector{}, err
+    }
+    return Vector { X: values[0], Y: values[1], Z: values[2] }, nil
+}
+
+// Reads the acceleration with gravity taken out, in m/s². Needs a
+// fusion mode.
+//
+func (d *BNO055Driver) LinearAcceleration() (Vector, error) {
+    return d.readVector(BNO055_LIA_DATA)
+}
+
+// Reads the acceleration due to gravity alone, in m/s². Needs a
+// fusion mode.
+//
+func (d *BNO055Driver) Gravity() (Vector, error) {
+    return d.readVector(BNO055_GRV_DATA)
+}
+
+// Reads the temperature in Celsius.
+//
+func (d *BNO055Driver) Temperature() (int, error) {
+    data, err := d.readRegisters(BNO055_TEMP, 1)
+    if err != nil {
+        return 0, err
+    }
+    return int(int8(data[0])), nil
+}
+
+// Reads the calibration levels.
+//
+func (d *BNO055Driver) CalibrationStatus() (BNO055Calibration, error) {
+    data, err := d.readRegisters(BNO055_CALIB_STAT, 1)
+    if err != nil {
+        return BNO055Calibration{}, err
+    }
+
+    status := int(data[0])
+    return BNO055Calibration {
+        System: status >> 6 & 3,
+        Gyroscope: status >> 4 & 3,
+        Accelerometer: status >> 2 & 3,
+        Magnetometer: status & 3,
+    }, nil
+}
+
+// Reads the calibration offsets, to be saved once the BNO055 is fully
+// calibrated and restored with SetCalibrationOffsets after it next
+// starts. The offsets are only readable in CONFIG mode, so the BNO055
+// is switched there and back.
+//
+func (d *BNO055Driver) CalibrationOffsets() (offsets BNO055Offsets, err error) {
+    err = d.inConfigMode(func() error {
+        data, err := d.readRegisters(BNO055_OFFSETS, BNO055_OFFSETS_LENGTH)
+        copy(offsets[:], data)
+        return err
+    })
+    return offsets, err
+}
+
+// Writes calibration offsets saved by CalibrationOffsets, switching to
+// CONFIG mode and back to do it.
+//
+func (d *BNO055Driver) SetCalibrationOffsets(offsets BNO055Offsets) error {
+    return d.inConfigMode(func() error {
+        return d.connection.WriteBlockData(BNO055_OFFSETS, offsets[:])
+    })
+}
+
+// Runs f in CONFIG mode, then returns to the current mode.
+//
+func (d *BNO055Driver) inConfigMode(f func() error) error {
+    if d.connection == nil {
+        return fmt.Errorf(" %s is not started", d.name)
+    }
+
+    d.mutex.Lock()
+    defer d.mutex.Unlock()
+
+    mode := d.mode
+    if err := d.setMode(BNO055_MODE_CONFIG) ; err != nil {
+        return err
+    }
+
+    err := f()
+    if restore := d.setMode(mode) ; err == nil {
+        err = restore
+    }
+    return err
+}
+
+// Puts the BNO055 in CONFIG mode, which stops the sensors, and closes
+// the connection.
+//
+func (d *BNO055Driver) Close() {
+    if d.connection != nil {
+        d.SetMode(BNO055_MODE_CONFIG)
+        d.connection.Close()
+    }
+}
+
+// A simulated BNO055, for running BNO055Driver without hardware.
+//
+// The ID registers hold the real chip's values. Mode changes are
+// tracked, the calibration offsets are only writable in CONFIG mode as
+// on the real chip, and a reset restores the power-on registers. The
+// Set functions put readings in the data registers in the chip's units.
+//
+type SimulatedBNO055 struct {
+    *SimulatedConnection
+    offsets BNO055Offsets
+}
+
+func NewSimulatedBNO055() *SimulatedBNO055 {
+    sim := &SimulatedBNO055 {
+        SimulatedConnection: NewSimulatedConnection(),
+    }
+
+    sim.reset()
+    sim.OnWrite = sim.onWrite
+
+    return sim
+}
+
+// Sets the power-on register values. Call with the connection locked.
+//
+func (s *SimulatedBNO055) reset() {
+    s.Registers = [256]byte{}
+    s.offsets = BNO055Offsets{}
+    s.Registers[BNO055_CHIP_ID] = BNO055_ID
+    s.Registers[BNO055_ACC_ID] = 0xFB
+    s.Registers[BNO055_MAG_ID] = 0x32
+    s.Registers[BNO055_GYR_ID] = 0x0F
+    s.Registers[BNO055_ST_RESULT] = 0x0F
+    s.Registers[BNO055_UNIT_SEL] = BNO055_UNITS
+}
+
+// The BNO055's mode, as last written.
+//
+func (s *SimulatedBNO055) Mode() BNO055Mode {
+    return BNO055Mode(s.Register(BNO055_OPR_MODE))
+}
+
+// The offsets registers, as last written in CONFIG mode.
+//
+func (s *SimulatedBNO055) Offsets() (offsets BNO055Offsets) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    return s.offsets
+}
+
+func (s *SimulatedBNO055) onWrite(reg uint8, val uint8) {
+    switch {
+    case reg == BNO055_SYS_TRIGGER && val & BNO055_TRIGGER_RESET != 0:
+        s.reset()
+    case reg >= BNO055_OFFSETS && int(reg) < int(BNO055_OFFSETS) + BNO055_OFFSETS_LENGTH:
+        // Outside CONFIG mode the write is lost.
+        //
+        i := int(reg - BNO055_OFFSETS)
+        if BNO055Mode(s.Registers[BNO055_OPR_MODE]) == BNO055_MODE_CONFIG {
+            s.offsets[i] = val
+        } else {
+            s.Registers[reg] = s.offsets[i]
+        }
+    }
+}
+
+// Stores count little endian signed 16-bit values, each multiplied by
+// scale.
+//
+func (s *SimulatedBNO055) setScaled(reg byte, scale float64, values ...float64) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    for i, value := range values {
+        raw := uint16(int16(value * scale))
+        s.Registers[int(reg) + 2 * i] = byte(raw)
+        s.Registers[int(reg) + 2 * i + 1] = byte(raw >> 8)
+    }
+}
+
+func (s *SimulatedBNO055) SetEuler(e Euler) {
+    s.setScaled(BNO055_EUL_DATA, 16, e.Heading, e.Roll, e.Pitch)
+}
+
+func (s *SimulatedBNO055) SetQuaternion(q Quaternion) {
+    s.setScaled(BNO055_QUA_DATA, 1 << 14, q.W, q.X, q.Y, q.Z)
+}
+
+func (s *SimulatedBNO055) SetLinearAcceleration(v Vector) {
+    s.setScaled(BNO055_LIA_DATA, 100, v.X, v.Y, v.Z)
+}
+
+func (s *SimulatedBNO055) SetGravity(v Vector) {
+    s.setScaled(BNO055_GRV_DATA, 100, v.X, v.Y, v.Z)
+}
+
+func (s *SimulatedBNO055) SetTemperature(celsius int) {
+    s.SetRegister(BNO055_TEMP, byte(int8(celsius)))
+}
+
+func (s *SimulatedBNO055) SetCalibration(c BNO055Calibration) {
+    s.SetRegister(BNO055_CALIB_STAT,
+        byte(c.System & 3 << 6 | c.Gyroscope & 3 << 4 | c.Accelerometer & 3 << 2 | c.Magnetometer & 3))
+}
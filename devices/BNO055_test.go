@@ -0,0 +1,234 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "math"
+    "testing"
+)
+
+func startSimulatedBNO055(t *testing.T) (*BNO055Driver, *SimulatedBNO055) {
+    sim := NewSimulatedBNO055()
+    driver := NewBNO055Driver(BNO055_DEFAULT_ADDRESS)
+
+    if err := driver.StartWithConnection(sim) ; err != nil {
+        t.Fatal(err)
+    }
+    return driver, sim
+}
+
+func closeTo(a, b float64) bool {
+    return math.Abs(a - b) < 1e-9
+}
+
+func TestBNO055Start(t *testing.T) {
+    driver, sim := startSimulatedBNO055(t)
+
+    if driver.Mode() != BNO055_MODE_NDOF || sim.Mode() != BNO055_MODE_NDOF {
+        t.Errorf("mode is %s, %s on the chip, not NDOF", driver.Mode(), sim.Mode())
+    }
+    if units := sim.Register(BNO055_UNIT_SEL) ; units != BNO055_UNITS {
+        t.Errorf("UNIT_SEL is 0x%02x, not 0x%02x", units, BNO055_UNITS)
+    }
+
+    chip, acc, mag, gyr, err := driver.ChipIDs()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if chip != BNO055_ID || acc != 0xFB || mag != 0x32 || gyr != 0x0F {
+        t.Errorf("chip IDs are %02x %02x %02x %02x", chip, acc, mag, gyr)
+    }
+
+    sim.SetRegister(BNO055_CHIP_ID, 0x55)
+    if err := NewBNO055Driver(BNO055_DEFAULT_ADDRESS).StartWithConnection(sim) ; err == nil {
+        t.Error("started with the wrong chip ID")
+    }
+}
+
+func TestBNO055Euler(t *testing.T) {
+    driver, sim := startSimulatedBNO055(t)
+
+    want := Euler { Heading: 359.9375, Roll: -45.5, Pitch: 12.25 }
+    sim.SetEuler(want)
+
+    got, err := driver.Euler()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got != want {
+        t.Errorf("Euler is %+v, not %+v", got, want)
+    }
+
+    // Heading is 1/16 degree per bit.
+    //
+    if raw := uint16(sim.Register(BNO055_EUL_DATA)) | uint16(sim.Register(BNO055_EUL_DATA + 1)) << 8 ; raw != 5759 {
+        t.Errorf("heading register is %d, not 5759", raw)
+    }
+}
+
+func TestBNO055Quaternion(t *testing.T) {
+    driver, sim := startSimulatedBNO055(t)
+
+    want := Quaternion { W: 0.5, X: -0.5, Y: 0.25, Z: -0.75 }
+    sim.SetQuaternion(want)
+
+    got, err := driver.Quaternion()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got != want {
+        t.Errorf("quaternion is %+v, not %+v", got, want)
+    }
+
+    // One is 2^14.
+    //
+    if raw := uint16(sim.Register(BNO055_QUA_DATA)) | uint16(sim.Register(BNO055_QUA_DATA + 1)) << 8 ; raw != 1 << 13 {
+        t.Errorf("W register is %d, not %d", raw, 1 << 13)
+    }
+}
+
+func TestBNO055Vectors(t *testing.T) {
+    driver, sim := startSimulatedBNO055(t)
+
+    linear := Vector { X: 1.25, Y: -0.5, Z: 0.01 }
+    gravity := Vector { X: 0, Y: -0.75, Z: 9.8 }
+    sim.SetLinearAcceleration(linear)
+    sim.SetGravity(gravity)
+
+    for _, test := range []struct {
+        name string
+        read func() (Vector, error)
+        want Vector
+    } {
+        { "linear acceleration", driver.LinearAcceleration, linear },
+        { "gravity", driver.Gravity, gravity },
+    } {
+        got, err := test.read()
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !closeTo(got.X, test.want.X) || !closeTo(got.Y, test.want.Y) || !closeTo(got.Z, test.want.Z) {
+            t.Errorf("%s is %+v, not %+v", test.name, got, test.want)
+        }
+    }
+
+    // Accelerations are 1/100 m/s² per bit.
+    //
+    if raw := uint16(sim.Register(BNO055_GRV_DATA + 4)) | uint16(sim.Register(BNO055_GRV_DATA + 5)) << 8 ; raw != 980 {
+        t.Errorf("gravity Z register is %d, not 980", raw)
+    }
+}
+
+func TestBNO055NeedsFusion(t *testing.T) {
+    driver, _ := startSimulatedBNO055(t)
+
+    if err := driver.SetMode(BNO055_MODE_AMG) ; err != nil {
+        t.Fatal(err)
+    }
+    if _, err := driver.Euler() ; err == nil {
+        t.Error("Euler read in AMG mode")
+    }
+    if _, err := driver.Quaternion() ; err == nil {
+        t.Error("quaternion read in AMG mode")
+    }
+    if _, err := driver.Gravity() ; err == nil {
+        t.Error("gravity read in AMG mode")
+    }
+}
+
+func TestBNO055TemperatureAndCalibration(t *testing.T) {
+    driver, sim := startSimulatedBNO055(t)
+
+    sim.SetTemperature(-7)
+    if celsius, err := driver.Temperature() ; err != nil || celsius != -7 {
+        t.Errorf("temperature is %d, %v, not -7", celsius, err)
+    }
+
+    want := BNO055Calibration { System: 1, Gyroscope: 3, Accelerometer: 0, Magnetometer: 2 }
+    sim.SetCalibration(want)
+    if stat := sim.Register(BNO055_CALIB_STAT) ; stat != 0x72 {
+        t.Errorf("CALIB_STAT is 0x%02x, not 0x72", stat)
+    }
+
+    got, err := driver.CalibrationStatus()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got != want || got.Calibrated() {
+        t.Errorf("calibration is %s, calibrated %v, not %s", got, got.Calibrated(), want)
+    }
+    if got.String() != "S1 G3 A0 M2" {
+        t.Errorf("calibration prints as %q", got.String())
+    }
+
+    sim.SetCalibration(BNO055Calibration { 3, 3, 3, 3 })
+    if got, _ := driver.CalibrationStatus() ; !got.Calibrated() {
+        t.Errorf("%s is not calibrated", got)
+    }
+}
+
+func TestBNO055OffsetsRoundTrip(t *testing.T) {
+    driver, sim := startSimulatedBNO055(t)
+
+    var saved BNO055Offsets
+    for i := range saved {
+        saved[i] = byte(0x10 + i)
+    }
+
+    if err := driver.SetCalibrationOffsets(saved) ; err != nil {
+        t.Fatal(err)
+    }
+    if sim.Offsets() != saved {
+        t.Errorf("the chip holds % x, not % x", sim.Offsets(), saved)
+    }
+    if driver.Mode() != BNO055_MODE_NDOF || sim.Mode() != BNO055_MODE_NDOF {
+        t.Errorf("mode is %s after writing offsets, not NDOF", sim.Mode())
+    }
+
+    // Outside CONFIG mode the chip ignores writes to the offsets.
+    //
+    sim.WriteByteData(BNO055_OFFSETS, 0xEE)
+    if sim.Offsets() != saved {
+        t.Error("offsets were written outside CONFIG mode")
+    }
+
+    restored, err := driver.CalibrationOffsets()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if restored != saved {
+        t.Errorf("offsets read back as % x, not % x", restored, saved)
+    }
+    if driver.Mode() != BNO055_MODE_NDOF {
+        t.Errorf("mode is %s after reading offsets, not NDOF", driver.Mode())
+    }
+
+    // A reset loses the offsets, and saved ones restore them.
+    //
+    if err := driver.Reset() ; err != nil {
+        t.Fatal(err)
+    }
+    if sim.Offsets() != (BNO055Offsets{}) {
+        t.Error("offsets survived a reset")
+    }
+    if err := driver.SetCalibrationOffsets(restored) ; err != nil {
+        t.Fatal(err)
+    }
+    if sim.Offsets() != saved {
+        t.Errorf("the chip holds % x after restoring, not % x", sim.Offsets(), saved)
+    }
+}
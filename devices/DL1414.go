@@ -0,0 +1,314 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// DL1414_CHARS is the number of characters in one display package.
+//
+const DL1414_CHARS int = 4
+
+// NO_PIN marks a display signal that isn't wired to the port expander.
+//
+const NO_PIN int = -1
+
+// How DL1414 or DL2416 displays are wired to an MCP23017. Pins are
+// MCP23017 pin numbers, 0-7 for GPA0-GPA7 and 8-15 for GPB0-GPB7.
+//
+// D0-D6 of every package are wired in parallel to bits 0-6 of DataPort.
+// A0 and A1 are likewise shared. Each package has its own write strobe,
+// listed in WritePins with package 0 on the right. On a DL1414, or a
+// DL2416 with /CE1 and /CE2 tied low, the write strobe is all that
+// selects a package; otherwise ChipEnablePins lists each package's
+// chip enable in the same order.
+//
+// The remaining signals only exist on the DL2416, and are shared by
+// every package. Set them to NO_PIN when they aren't wired.
+//
+type DL1414Wiring struct {
+    DataPort Port
+    AddressPins [2]int
+    WritePins []int
+    ChipEnablePins []int
+    ClearPin int
+    BlankPin int
+    CursorPin int
+    CursorEnablePin int
+}
+
+// The wiring used by rpi intdisplay: four packages with data on port
+// B, A0 and A1 on GPA0 and GPA1, and the combined /WR and /CE of
+// packages 0-3 on GPA4-GPA7.
+//
+func IntDisplayWiring() DL1414Wiring {
+    return DL1414Wiring {
+        DataPort: PORT_B,
+        AddressPins: [2]int{0, 1},
+        WritePins: []int{4, 5, 6, 7},
+        ClearPin: NO_PIN,
+        BlankPin: NO_PIN,
+        CursorPin: NO_PIN,
+        CursorEnablePin: NO_PIN,
+    }
+}
+
+// A driver for a row of DL1414 or DL2416 four character intelligent
+// displays, driven through an MCP23017.
+//
+// Characters are addressed by location, 0 being the rightmost
+// character of package 0, as the displays number their digits.
+// Write and Scroll take text left to right like every TextDisplay.
+//
+type DL1414Display struct {
+    name string
+    mcp *MCP23017Driver
+    wiring DL1414Wiring
+}
+
+func NewDL1414Display(mcp *MCP23017Driver, wiring DL1414Wiring) *DL1414Display {
+    display := &DL1414Display {
+        name: "DL1414",
+        mcp: mcp,
+        wiring: wiring,
+    }
+
+    return display
+}
+
+func (d *DL1414Display) Name() string { return d.name }
+func (d *DL1414Display) SetName(newName string ) { d.name = newName }
+func (d *DL1414Display) MCP23017() *MCP23017Driver { return d.mcp }
+func (d *DL1414Display) Wiring() DL1414Wiring { return d.wiring }
+
+// Returns a mask with the bit for each wired pin set.
+//
+func pinMask(pins ...int) (mask uint16) {
+    for _, pin := range pins {
+        if pin != NO_PIN {
+            mask |= 1 << uint(pin)
+        }
+    }
+    return mask
+}
+
+func (d *DL1414Display) dataMask() uint16 {
+    return uint16(0x7F) << (uint(d.wiring.DataPort) * 8)
+}
+
+// The active low control lines that idle high.
+//
+func (d *DL1414Display) idleHighMask() uint16 {
+    pins := append(append([]int{}, d.wiring.WritePins...), d.wiring.ChipEnablePins...)
+    return pinMask(append(pins, d.wiring.ClearPin, d.wiring.BlankPin, d.wiring.CursorPin)...)
+}
+
+// Makes every wired pin an output and puts the control lines in their
+// idle state. The display is not cleared.
+//
+func (d *DL1414Display) Start() error {
+    mask := d.dataMask() | d.idleHighMask() | pinMask(d.wiring.AddressPins[:]...) |
+        pinMask(d.wiring.CursorEnablePin)
+
+    if err := d.mcp.WriteMasked(mask, d.idleHighMask()) ; err != nil {
+        return err
+    }
+
+    for pin := 0 ; pin < MCP23017_PINS ; pin++ {
+        if mask & (1 << uint(pin)) != 0 {
+            if err := d.mcp.PinMode(pin, PIN_OUTPUT) ; err != nil {
+                return err
+            }
+        }
+    }
+
+    return nil
+}
+
+// Width, Write and Scroll, along with Clear, implement TextDisplay.
+// Width is the number of characters across all packages.
+//
+func (d *DL1414Display) Width() int { return len(d.wiring.WritePins) * DL1414_CHARS }
+
+// Writes a character into display or cursor memory: the data and
+// address are set up, then the write strobe (and chip enable, if
+// wired) are pulsed low.
+//
+func (d *DL1414Display) strobe(location int, data byte) error {
+    if location < 0 || location >= d.Width() {
+        return fmt.Errorf(" Location %d is out of range 0-%d", location, d.Width() - 1)
+    }
+
+    block := location / DL1414_CHARS
+    digit := location % DL1414_CHARS
+
+    var address uint16
+    for bit, pin := range d.wiring.AddressPins {
+        if digit & (1 << uint(bit)) != 0 {
+            address |= pinMask(pin)
+        }
+    }
+
+    setup := d.dataMask() | pinMask(d.wiring.AddressPins[:]...)
+    value := address | uint16(data & 0x7F) << (uint(d.wiring.DataPort) * 8)
+    if err := d.mcp.WriteMasked(setup, value) ; err != nil {
+        return err
+    }
+
+    var enable uint16
+    if block < len(d.wiring.ChipEnablePins) {
+        enable = pinMask(d.wiring.ChipEnablePins[block])
+    }
+    write := pinMask(d.wiring.WritePins[block])
+
+    steps := []struct{ mask, value uint16 } {
+        { enable, 0 },
+        { write, 0 },
+        { write, write },
+        { enable, enable },
+    }
+
+    for _, step := range steps {
+        if step.mask == 0 {
+            continue
+        }
+        if err := d.mcp.WriteMasked(step.mask, step.value) ; err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// Writes a single character. The displays only have upper case, so
+// lower case letters are converted.
+//
+func (d *DL1414Display) WriteCharacter(location int, char byte) error {
+    if char >= 'a' && char <= 'z' {
+        char -= 'a' - 'A'
+    }
+
+    return d.strobe(location, char)
+}
+
+// Writes text left justified across all the packages, blanking any
+// characters it doesn't reach.
+//
+func (d *DL1414Display) Write(text string) {
+    text = fitText(text, d.Width())
+
+    location := d.Width() - 1
+    for _, char := range []byte(text) {
+        d.WriteCharacter(location, char)
+        location--
+    }
+}
+
+// Scrolls text across all the packages from right to left.
+//
+func (d *DL1414Display) Scroll(text string) {
+    padding := strings.Repeat(" ", d.Width())
+    text = padding + text + padding
+
+    for i := 0 ; i + d.Width() <= len(text) ; i++ {
+        d.Write(text[i:i + d.Width()])
+        time.Sleep(250 * time.Millisecond)
+    }
+}
+
+// Blanks every character. A DL2416 with /CLR wired is cleared with
+// a single pulse, which also clears the cursor memory.
+//
+func (d *DL1414Display) Clear() {
+    if d.wiring.ClearPin != NO_PIN {
+        clear := pinMask(d.wiring.ClearPin)
+        d.mcp.WriteMasked(clear, 0)
+        d.mcp.WriteMasked(clear, clear)
+        return
+    }
+
+    d.Write("")
+}
+
+func (d *DL1414Display) requirePin(pin int, signal string) error {
+    if pin == NO_PIN {
+        return fmt.Errorf(" %s is not wired on %s", signal, d.name)
+    }
+    return nil
+}
+
+// DL2416 only. Turns the cursor at a location on or off. Cursors are
+// only shown while the cursor is enabled with EnableCursor.
+//
+func (d *DL1414Display) SetCursor(location int, on bool) error {
+    if err := d.requirePin(d.wiring.CursorPin, "/CU") ; err != nil {
+        return err
+    }
+
+    cursor := pinMask(d.wiring.CursorPin)
+    if err := d.mcp.WriteMasked(cursor, 0) ; err != nil {
+        return err
+    }
+
+    var data byte
+    if on { data = 1 }
+    err := d.strobe(location, data)
+
+    if restore := d.mcp.WriteMasked(cursor, cursor) ; err == nil {
+        err = restore
+    }
+    return err
+}
+
+// DL2416 only. Shows or hides the cursors set with SetCursor.
+//
+func (d *DL1414Display) EnableCursor(on bool) error {
+    if err := d.requirePin(d.wiring.CursorEnablePin, "CUE") ; err != nil {
+        return err
+    }
+
+    enable := pinMask(d.wiring.CursorEnablePin)
+    if on {
+        return d.mcp.WriteMasked(enable, enable)
+    }
+    return d.mcp.WriteMasked(enable, 0)
+}
+
+// DL2416 only. Blanks the whole display without losing its contents.
+//
+func (d *DL1414Display) Blank(on bool) error {
+    if err := d.requirePin(d.wiring.BlankPin, "/BL") ; err != nil {
+        return err
+    }
+
+    blank := pinMask(d.wiring.BlankPin)
+    if on {
+        return d.mcp.WriteMasked(blank, 0)
+    }
+    return d.mcp.WriteMasked(blank, blank)
+}
+
+// Clears the display, then closes the MCP23017.
+// Call this last before exiting an application.
+//
+func (d *DL1414Display) Close() {
+    d.Clear()
+    d.mcp.Close()
+}
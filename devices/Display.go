@@ -0,0 +1,118 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "image"
+    "image/color"
+    "time"
+)
+
+// TextDisplay is implemented by every display that can show characters,
+// so that clocks, tickers and the like can be written once and run on
+// whatever display is attached.
+//
+// Width is the number of characters that fit on the display at once.
+// Write replaces everything on the display with text, blanking any
+// characters it doesn't reach. Scroll moves text across the display
+// from right to left and returns once it has scrolled off.
+//
+type TextDisplay interface {
+    Width() int
+    Write(text string)
+    Scroll(text string)
+    Clear()
+}
+
+// PixelDisplay is implemented by every display made of individually
+// addressable LEDs.
+//
+// Bounds always has its minimum at 0,0. Set changes a pixel in the
+// display's buffer, picking the closest color the LEDs can show.
+// Nothing changes on the display itself until Flush is called.
+//
+type PixelDisplay interface {
+    Bounds() image.Rectangle
+    Set(x, y int, c color.Color)
+    Flush()
+}
+
+// Time between each column when scrolling text across a matrix.
+//
+const matrixScrollDelay = 50 * time.Millisecond
+
+// Whether a color should light a single color LED.
+//
+func lit(c color.Color) bool {
+    if _, _, _, alpha := c.RGBA(); alpha == 0 {
+        return false
+    }
+    return color.GrayModel.Convert(c).(color.Gray).Y >= 0x80
+}
+
+// Renders text as a strip of columns in the VT52 font.
+//
+func vt52Strip(text string) []byte {
+    var strip []byte
+    for _, char := range text {
+        strip = append(strip, GetVT52Character(int(char))...)
+    }
+    return strip
+}
+
+// Scrolls text in the VT52 font across a matrix width columns wide.
+// Each window of columns is handed to show, which draws it.
+//
+func scrollMatrix(text string, width int, show func(columns []byte)) {
+    blank := make([]byte, width)
+    strip := append(append(append([]byte{}, blank...), vt52Strip(text)...), blank...)
+
+    for i := 0 ; i + width <= len(strip) ; i++ {
+        show(strip[i:i + width])
+        time.Sleep(matrixScrollDelay)
+    }
+}
+
+// Pads or trims text to exactly width characters, keeping it left
+// justified.
+//
+func fitText(text string, width int) string {
+    runes := []rune(text)
+    for len(runes) < width {
+        runes = append(runes, ' ')
+    }
+    return string(runes[:width])
+}
+
+// Every display driver, checked at compile time against the interfaces
+// it implements.
+//
+var (
+    _ TextDisplay = (*Adafruit54AlphaDisplay)(nil)
+    _ TextDisplay = (*Adafruit7SegmentDisplay)(nil)
+    _ TextDisplay = (*Adafruit816LedMatrix)(nil)
+    _ TextDisplay = (*Adafruit88LedMatrix)(nil)
+    _ TextDisplay = (*AdafruitBicolor88Matrix)(nil)
+    _ TextDisplay = (*DisplayClient)(nil)
+    _ TextDisplay = (*DL1414Display)(nil)
+    _ TextDisplay = (*HDSP2111Display)(nil)
+
+    _ PixelDisplay = (*Adafruit816LedMatrix)(nil)
+    _ PixelDisplay = (*Adafruit88LedMatrix)(nil)
+    _ PixelDisplay = (*AdafruitBicolor88Matrix)(nil)
+    _ PixelDisplay = (*Adafruit24Bargraph)(nil)
+)
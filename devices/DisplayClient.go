@@ -0,0 +1,157 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "bufio"
+    "encoding/hex"
+    "fmt"
+    "net"
+    "strconv"
+    "strings"
+)
+
+// DISPLAYD_SOCKET is the Unix socket apps/displayd listens on unless
+// configured otherwise.
+//
+const DISPLAYD_SOCKET = "/tmp/displayd.sock"
+
+// A client for the line protocol apps/displayd serves on its Unix
+// socket, so that a program can share displays the daemon owns instead
+// of opening the I2C bus itself. Each command is one line, answered by
+// a line starting with OK or ERR.
+//
+//    DISPLAY [name]  - Selects a display, by default the first one,
+//                      replying OK with its name and width.
+//    PRINT text      - Writes text on the display.
+//    SCROLL text     - Scrolls text across the display.
+//    CLEAR           - Blanks the display.
+//    BRIGHT level    - Sets the brightness from 0 to 15.
+//    FRAME hex       - Shows column bytes on a matrix, as in rpi animate.
+//    WAIT            - Replies once everything sent before it is shown.
+//    STATE           - Replies OK with the display's state as JSON.
+//
+// DisplayClient implements TextDisplay. As with the drivers, Scroll
+// returns once the text has scrolled off.
+//
+type DisplayClient struct {
+    name string
+    connection net.Conn
+    reader *bufio.Reader
+    display string
+    width int
+}
+
+// Connects to displayd and selects a display by name, or the daemon's
+// first display when display is empty.
+//
+func NewDisplayClient(path string, display string) (*DisplayClient, error) {
+    connection, err := net.Dial("unix", path)
+    if err != nil {
+        return nil, err
+    }
+
+    client := &DisplayClient {
+        name: "DisplayClient",
+        connection: connection,
+        reader: bufio.NewReader(connection),
+    }
+
+    reply, err := client.Command("DISPLAY", display)
+    if err == nil {
+        fields := strings.Fields(reply)
+        if len(fields) != 2 {
+            err = fmt.Errorf(" Unexpected reply from displayd: %q", reply)
+        } else {
+            client.display = fields[0]
+            client.width, err = strconv.Atoi(fields[1])
+        }
+    }
+    if err != nil {
+        connection.Close()
+        return nil, err
+    }
+
+    return client, nil
+}
+
+func (c *DisplayClient) Name() string { return c.name }
+func (c *DisplayClient) SetName(newName string) { c.name = newName }
+func (c *DisplayClient) Display() string { return c.display }
+func (c *DisplayClient) Width() int { return c.width }
+
+// Sends one command and returns whatever followed OK in the reply.
+// Line breaks in the argument are sent as spaces.
+//
+func (c *DisplayClient) Command(command string, argument string) (string, error) {
+    line := command
+    if len(argument) > 0 {
+        line += " " + strings.NewReplacer("\r", " ", "\n", " ").Replace(argument)
+    }
+    if _, err := fmt.Fprintf(c.connection, "%s\n", line) ; err != nil {
+        return "", err
+    }
+
+    reply, err := c.reader.ReadString('\n')
+    if err != nil {
+        return "", err
+    }
+    reply = strings.TrimRight(reply, "\r\n")
+
+    switch {
+    case reply == "OK":
+        return "", nil
+    case strings.HasPrefix(reply, "OK "):
+        return reply[3:], nil
+    case strings.HasPrefix(reply, "ERR "):
+        return "", fmt.Errorf(" %s", reply[4:])
+    }
+    return "", fmt.Errorf(" Unexpected reply from displayd: %q", reply)
+}
+
+func (c *DisplayClient) Write(text string) {
+    c.Command("PRINT", text)
+}
+
+func (c *DisplayClient) Scroll(text string) {
+    if _, err := c.Command("SCROLL", text) ; err == nil {
+        c.Command("WAIT", "")
+    }
+}
+
+func (c *DisplayClient) Clear() {
+    c.Command("CLEAR", "")
+}
+
+func (c *DisplayClient) SetBrightness(level int) error {
+    _, err := c.Command("BRIGHT", strconv.Itoa(level))
+    return err
+}
+
+// Shows a frame of column bytes, leftmost column first with bit 0x80
+// at the top.
+//
+func (c *DisplayClient) ShowFrame(columns []byte) error {
+    _, err := c.Command("FRAME", hex.EncodeToString(columns))
+    return err
+}
+
+// Closes the connection, leaving the display as it is.
+//
+func (c *DisplayClient) Close() {
+    c.connection.Close()
+}
@@ -0,0 +1,351 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// HDSP2111_CHARS is the number of characters in one display.
+//
+const HDSP2111_CHARS int = 8
+
+// How HP/Avago HDSP-2111 or HDSP-2113 displays are wired to MCP23017s.
+//
+// Pins 0-15 are on the first MCP23017 passed to NewHDSP2111Display,
+// 0-7 for GPA0-GPA7 and 8-15 for GPB0-GPB7. Pins 16-31 are on the
+// second, if there is one.
+//
+// D0-D7 of every display are wired in parallel to DataPort of the first
+// MCP23017, as are A0-A2 and /RST. Each display has its own /CE and
+// /WR, listed in ChipEnablePins and WritePins with display 0 on the left.
+//
+// A3, A4 and /FL select the display's other memories. When they are
+// NO_PIN they are assumed to be tied high, which selects character RAM.
+// /RD is only needed to read back the self-test result.
+//
+type HDSP2111Wiring struct {
+    DataPort Port
+    AddressPins [3]int
+    ChipEnablePins []int
+    WritePins []int
+    ResetPin int
+    A3Pin int
+    A4Pin int
+    FlashPin int
+    ReadPin int
+}
+
+// The wiring in I2Cpp/HDSP.h: two displays on an MCP23017, with D0-D7
+// on port A, then A0-A2, /CE1, /WR1, /CE2, /WR2 and /RST on GPB0-GPB7.
+//
+func HDSPWiring() HDSP2111Wiring {
+    return HDSP2111Wiring {
+        DataPort: PORT_A,
+        AddressPins: [3]int{8, 9, 10},
+        ChipEnablePins: []int{11, 13},
+        WritePins: []int{12, 14},
+        ResetPin: 15,
+        A3Pin: NO_PIN,
+        A4Pin: NO_PIN,
+        FlashPin: NO_PIN,
+        ReadPin: NO_PIN,
+    }
+}
+
+// The HDSP.h wiring, extended with A3, A4, /FL and /RD on GPA0-GPA3
+// of a second MCP23017, which reaches the user defined characters,
+// control word and flash RAM. Port B of the second MCP23017 is left
+// free for the inputs in I2Cpp/Inputs.h.
+//
+func HDSPExtendedWiring() HDSP2111Wiring {
+    wiring := HDSPWiring()
+    wiring.A3Pin = MCP23017_PINS + 0
+    wiring.A4Pin = MCP23017_PINS + 1
+    wiring.FlashPin = MCP23017_PINS + 2
+    wiring.ReadPin = MCP23017_PINS + 3
+    return wiring
+}
+
+// A driver for one or more HDSP-2111 eight character displays, driven
+// through one or two MCP23017s. Location 0 is the leftmost character
+// of display 0.
+//
+type HDSP2111Display struct {
+    name string
+    expanders []*MCP23017Driver
+    wiring HDSP2111Wiring
+    control []byte
+}
+
+func NewHDSP2111Display(wiring HDSP2111Wiring, expanders ...*MCP23017Driver) *HDSP2111Display {
+    display := &HDSP2111Display {
+        name: "HDSP2111",
+        expanders: expanders,
+        wiring: wiring,
+        control: make([]byte, len(wiring.WritePins)),
+    }
+
+    return display
+}
+
+func (d *HDSP2111Display) Name() string { return d.name }
+func (d *HDSP2111Display) SetName(newName string ) { d.name = newName }
+func (d *HDSP2111Display) Wiring() HDSP2111Wiring { return d.wiring }
+
+// Width, Write and Scroll, along with Clear, implement TextDisplay.
+// Width is the number of characters across all displays.
+//
+func (d *HDSP2111Display) Width() int { return len(d.wiring.WritePins) * HDSP2111_CHARS }
+
+// Changes the selected pins, which may be spread over both MCP23017s.
+//
+func (d *HDSP2111Display) writePins(mask uint32, val uint32) error {
+    for i, expander := range d.expanders {
+        shift := uint(i * MCP23017_PINS)
+        portMask := uint16(mask >> shift)
+        if portMask == 0 {
+            continue
+        }
+        if err := expander.WriteMasked(portMask, uint16(val >> shift)) ; err != nil {
+            return err
+        }
+    }
+
+    if mask >> uint(len(d.expanders) * MCP23017_PINS) != 0 {
+        return fmt.Errorf(" %s is wired to more MCP23017s than it was given", d.name)
+    }
+    return nil
+}
+
+// Returns a mask with the bit for each wired pin set, across both
+// MCP23017s.
+//
+func pinMask32(pins ...int) (mask uint32) {
+    for _, pin := range pins {
+        if pin != NO_PIN {
+            mask |= 1 << uint(pin)
+        }
+    }
+    return mask
+}
+
+func (d *HDSP2111Display) dataMask() uint32 {
+    return uint32(0xFF) << (uint(d.wiring.DataPort) * 8)
+}
+
+// The active low control lines that idle high.
+//
+func (d *HDSP2111Display) controlMask() uint32 {
+    pins := append(append([]int{}, d.wiring.ChipEnablePins...), d.wiring.WritePins...)
+    return pinMask32(append(pins, d.wiring.ResetPin, d.wiring.A3Pin, d.wiring.A4Pin, d.wiring.FlashPin,
+        d.wiring.ReadPin)...)
+}
+
+// Makes every wired pin an output, puts the control lines in their
+// idle state, then resets the displays.
+//
+func (d *HDSP2111Display) Start() error {
+    if len(d.expanders) == 0 {
+        return fmt.Errorf(" %s has no MCP23017 to drive it", d.name)
+    }
+
+    mask := d.dataMask() | d.controlMask() | pinMask32(d.wiring.AddressPins[:]...)
+    if err := d.writePins(mask, d.controlMask()) ; err != nil {
+        return err
+    }
+
+    for pin := 0 ; pin < len(d.expanders) * MCP23017_PINS ; pin++ {
+        if mask & (1 << uint(pin)) != 0 {
+            expander := d.expanders[pin / MCP23017_PINS]
+            if err := expander.PinMode(pin % MCP23017_PINS, PIN_OUTPUT) ; err != nil {
+                return err
+            }
+        }
+    }
+
+    return d.Reset()
+}
+
+// Pulses /RST, which blanks the character RAM and clears the flash
+// RAM and control word.
+//
+func (d *HDSP2111Display) Reset() error {
+    reset := pinMask32(d.wiring.ResetPin)
+    if reset == 0 {
+        d.Clear()
+        return nil
+    }
+
+    if err := d.writePins(reset, 0) ; err != nil {
+        return err
+    }
+
+    for display := range d.control {
+        d.control[display] = 0
+    }
+
+    // The displays need 300ns with /RST low, and 110us after before
+    // they can be written. The I2C writes alone take longer than that.
+    //
+    return d.writePins(reset, reset)
+}
+
+// Sets up the address lines and data, then pulses the selected
+// display's /CE and /WR low to write it.
+//
+// A3, A4 and /FL are only driven when they are wired, and select which
+// of the display's memories is written. Each is driven high when true.
+//
+func (d *HDSP2111Display) strobe(display int, address int, a3, a4, flash bool, data byte) error {
+    if display < 0 || display >= len(d.wiring.WritePins) {
+        return fmt.Errorf(" Display %d is out of range 0-%d", display, len(d.wiring.WritePins) - 1)
+    }
+
+    var value uint32
+    for bit, pin := range d.wiring.AddressPins {
+        if address & (1 << uint(bit)) != 0 {
+            value |= pinMask32(pin)
+        }
+    }
+    if a3 { value |= pinMask32(d.wiring.A3Pin) }
+    if a4 { value |= pinMask32(d.wiring.A4Pin) }
+    if flash { value |= pinMask32(d.wiring.FlashPin) }
+    value |= uint32(data) << (uint(d.wiring.DataPort) * 8)
+
+    setup := d.dataMask() | pinMask32(d.wiring.AddressPins[:]...) |
+        pinMask32(d.wiring.A3Pin, d.wiring.A4Pin, d.wiring.FlashPin)
+    if err := d.writePins(setup, value) ; err != nil {
+        return err
+    }
+
+    var enable uint32
+    if display < len(d.wiring.ChipEnablePins) {
+        enable = pinMask32(d.wiring.ChipEnablePins[display])
+    }
+    write := pinMask32(d.wiring.WritePins[display])
+
+    steps := []struct{ mask, value uint32 } {
+        { enable, 0 },
+        { write, 0 },
+        { write, write },
+        { enable, enable },
+    }
+
+    for _, step := range steps {
+        if step.mask == 0 {
+            continue
+        }
+        if err := d.writePins(step.mask, step.value) ; err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// Writes a single character into character RAM.
+//
+func (d *HDSP2111Display) WriteCharacter(location int, char byte) error {
+    if location < 0 || location >= d.Width() {
+        return fmt.Errorf(" Location %d is out of range 0-%d", location, d.Width() - 1)
+    }
+
+    return d.strobe(location / HDSP2111_CHARS, location % HDSP2111_CHARS, true, true, true, char)
+}
+
+// Writes text left justified across all the displays, blanking any
+// characters it doesn't reach.
+//
+func (d *HDSP2111Display) Write(text string) {
+    for location, char := range []byte(fitText(text, d.Width())) {
+        d.WriteCharacter(location, char)
+    }
+}
+
+// Writes text starting at a location, leaving the rest of the
+// display alone.
+//
+func (d *HDSP2111Display) WriteAt(location int, text string) {
+    for i, char := range []byte(text) {
+        d.WriteCharacter(location + i, char)
+    }
+}
+
+// Scrolls text across all the displays from right to left.
+//
+func (d *HDSP2111Display) Scroll(text string) {
+    padding := strings.Repeat(" ", d.Width())
+    text = padding + text + padding
+
+    for i := 0 ; i + d.Width() <= len(text) ; i++ {
+        d.Write(text[i:i + d.Width()])
+        time.Sleep(250 * time.Millisecond)
+    }
+}
+
+// Blanks every character.
+//
+func (d *HDSP2111Display) Clear() {
+    d.Write("")
+}
+
+// Writes the time as HH:MM:SS at a location. With toggle set the
+// colons alternate with periods every second, as a heartbeat.
+//
+func (d *HDSP2111Display) WriteClock(location int, t time.Time, toggle bool) {
+    first, second := ":", ":"
+    if toggle {
+        if t.Second() % 2 == 1 {
+            second = "."
+        } else {
+            first = "."
+        }
+    }
+
+    d.WriteAt(location, fmt.Sprintf("%02d%s%02d%s%02d", t.Hour(), first, t.Minute(), second, t.Second()))
+}
+
+// Writes the date as, for example, "Oct 19th" at a location.
+//
+func (d *HDSP2111Display) WriteDate(location int, t time.Time) {
+    d.WriteAt(location, fmt.Sprintf("%s %2d%s", t.Format("Jan"), t.Day(), ordinalSuffix(t.Day())))
+}
+
+func ordinalSuffix(day int) string {
+    switch day {
+    case 1, 21, 31:
+        return "st"
+    case 2, 22:
+        return "nd"
+    case 3, 23:
+        return "rd"
+    }
+    return "th"
+}
+
+// Resets the displays, which blanks them, then closes the MCP23017s.
+// Call this last before exiting an application.
+//
+func (d *HDSP2111Display) Close() {
+    d.Reset()
+    for _, expander := range d.expanders {
+        expander.Close()
+    }
+}
@@ -0,0 +1,323 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "fmt"
+    "time"
+)
+
+// The HDSP-2111's memories beyond character RAM. With /FL high, A4 and
+// A3 select the UDC address register (00), UDC RAM (01), the control
+// word (10) or character RAM (11). With /FL low, flash RAM is selected.
+//
+// Every function in this file needs A3, A4 and /FL wired, as in
+// HDSPExtendedWiring.
+//
+
+// HDSP2111_UDC_COUNT is the number of user defined characters, each
+// 5 columns by 7 rows.
+//
+const (
+    HDSP2111_UDC_COUNT int = 16
+    HDSP2111_UDC_COLUMNS int = 5
+    HDSP2111_UDC_ROWS int = 7
+)
+
+// Control word bits. Brightness is in D0-D2.
+//
+const (
+    HDSP2111_CONTROL_BRIGHTNESS byte = 0x07
+    HDSP2111_CONTROL_FLASH byte = 0x08
+    HDSP2111_CONTROL_BLINK byte = 0x10
+    HDSP2111_CONTROL_TEST_PASSED byte = 0x20
+    HDSP2111_CONTROL_SELF_TEST byte = 0x40
+    HDSP2111_CONTROL_CLEAR byte = 0x80
+)
+
+// HDSP2111_BRIGHTEST is the highest level SetBrightness takes. The
+// eight levels are 0%, 13%, 20%, 27%, 40%, 53%, 80% and 100%.
+//
+const HDSP2111_BRIGHTEST int = 7
+
+// HDSP2111_SELF_TEST_TIME is how long the self-test runs.
+//
+const HDSP2111_SELF_TEST_TIME = 5 * time.Second
+
+func (d *HDSP2111Display) requirePin(pin int, signal string) error {
+    if pin == NO_PIN {
+        return fmt.Errorf(" %s is not wired on %s", signal, d.name)
+    }
+    return nil
+}
+
+func (d *HDSP2111Display) requireMemoryPins() error {
+    if err := d.requirePin(d.wiring.A3Pin, "A3") ; err != nil {
+        return err
+    }
+    if err := d.requirePin(d.wiring.A4Pin, "A4") ; err != nil {
+        return err
+    }
+    return d.requirePin(d.wiring.FlashPin, "/FL")
+}
+
+// Writes the cached control word to one display.
+//
+func (d *HDSP2111Display) writeControl(display int) error {
+    return d.strobe(display, 0, false, true, true, d.control[display])
+}
+
+// Changes the control word bits in mask on every display.
+//
+func (d *HDSP2111Display) updateControl(mask byte, val byte) error {
+    if err := d.requireMemoryPins() ; err != nil {
+        return err
+    }
+
+    for display := range d.control {
+        d.control[display] = d.control[display] &^ mask | val & mask
+        if err := d.writeControl(display) ; err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Sets the brightness of every display, from 0 (off) to
+// HDSP2111_BRIGHTEST. The control word counts down from 100%.
+//
+func (d *HDSP2111Display) SetBrightness(level int) error {
+    if level < 0 || level > HDSP2111_BRIGHTEST {
+        return fmt.Errorf(" Brightness %d is out of range 0-%d", level, HDSP2111_BRIGHTEST)
+    }
+
+    return d.updateControl(HDSP2111_CONTROL_BRIGHTNESS, byte(HDSP2111_BRIGHTEST - level))
+}
+
+// Blinks every character of every display about twice a second.
+//
+func (d *HDSP2111Display) SetBlink(on bool) error {
+    var val byte
+    if on { val = HDSP2111_CONTROL_BLINK }
+    return d.updateControl(HDSP2111_CONTROL_BLINK, val)
+}
+
+// Enables or disables flashing of the characters marked with SetFlash,
+// without changing the marks.
+//
+func (d *HDSP2111Display) EnableFlash(on bool) error {
+    var val byte
+    if on { val = HDSP2111_CONTROL_FLASH }
+    return d.updateControl(HDSP2111_CONTROL_FLASH, val)
+}
+
+// Marks a single character to flash, or not, in flash RAM, and enables
+// flashing on its display.
+//
+func (d *HDSP2111Display) SetFlash(location int, on bool) error {
+    if err := d.requireMemoryPins() ; err != nil {
+        return err
+    }
+    if location < 0 || location >= d.Width() {
+        return fmt.Errorf(" Location %d is out of range 0-%d", location, d.Width() - 1)
+    }
+
+    var data byte
+    if on { data = 1 }
+
+    display := location / HDSP2111_CHARS
+    if err := d.strobe(display, location % HDSP2111_CHARS, false, false, false, data) ; err != nil {
+        return err
+    }
+
+    if on && d.control[display] & HDSP2111_CONTROL_FLASH == 0 {
+        d.control[display] |= HDSP2111_CONTROL_FLASH
+        return d.writeControl(display)
+    }
+    return nil
+}
+
+// Clears character RAM and flash RAM on every display, leaving the
+// user defined characters and the rest of the control word alone.
+//
+func (d *HDSP2111Display) ClearAll() error {
+    if err := d.requireMemoryPins() ; err != nil {
+        return err
+    }
+
+    for display := range d.control {
+        err := d.strobe(display, 0, false, true, true, d.control[display] | HDSP2111_CONTROL_CLEAR)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Loads a user defined character into UDC RAM of every display, as
+// seven rows top to bottom, with the leftmost column in bit 4 of each.
+//
+func (d *HDSP2111Display) DefineCharacterRows(index int, rows [7]byte) error {
+    if err := d.requireMemoryPins() ; err != nil {
+        return err
+    }
+    if index < 0 || index >= HDSP2111_UDC_COUNT {
+        return fmt.Errorf(" User defined character %d is out of range 0-%d", index, HDSP2111_UDC_COUNT - 1)
+    }
+
+    for display := range d.control {
+        if err := d.strobe(display, 0, false, false, true, byte(index)) ; err != nil {
+            return err
+        }
+
+        for row, bits := range rows {
+            if err := d.strobe(display, row, true, false, true, bits & 0x1F) ; err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// Loads a user defined character from up to five columns left to
+// right, in the VT52 and LED matrix format: the top row is bit 0x40
+// and the bottom bit 0x01. Bit 0x80 is ignored, as the VT52 glyphs
+// leave it blank.
+//
+func (d *HDSP2111Display) DefineCharacter(index int, columns []byte) error {
+    if len(columns) > HDSP2111_UDC_COLUMNS {
+        return fmt.Errorf(" A user defined character has at most %d columns", HDSP2111_UDC_COLUMNS)
+    }
+
+    var rows [7]byte
+    for column, bits := range columns {
+        for row := 0 ; row < HDSP2111_UDC_ROWS ; row++ {
+            if bits & (0x40 >> uint(row)) != 0 {
+                rows[row] |= 0x10 >> uint(column)
+            }
+        }
+    }
+
+    return d.DefineCharacterRows(index, rows)
+}
+
+// Loads a VT52 glyph as a user defined character. VT52 glyphs are up
+// to seven columns wide, so only the middle five are kept; glyphs that
+// fill the outer columns, like most letters, lose their sides.
+//
+func (d *HDSP2111Display) DefineVT52Character(index int, char int) error {
+    if char < 0 || char >= GetVT52Count() {
+        return fmt.Errorf(" VT52 character %d is out of range 0-%d", char, GetVT52Count() - 1)
+    }
+
+    return d.DefineCharacter(index, GetVT52Character(char)[2:7])
+}
+
+// Shows user defined character index at a location.
+//
+func (d *HDSP2111Display) WriteUserCharacter(location int, index int) error {
+    if index < 0 || index >= HDSP2111_UDC_COUNT {
+        return fmt.Errorf(" User defined character %d is out of range 0-%d", index, HDSP2111_UDC_COUNT - 1)
+    }
+
+    return d.WriteCharacter(location, 0x80 | byte(index))
+}
+
+// Reads a byte from one display: the data port is turned around to
+// inputs, then /CE and /RD are held low while it is read.
+//
+func (d *HDSP2111Display) read(display int, address int, a3, a4, flash bool) (byte, error) {
+    if err := d.requirePin(d.wiring.ReadPin, "/RD") ; err != nil {
+        return 0, err
+    }
+    if display < 0 || display >= len(d.wiring.WritePins) {
+        return 0, fmt.Errorf(" Display %d is out of range 0-%d", display, len(d.wiring.WritePins) - 1)
+    }
+
+    var value uint32
+    for bit, pin := range d.wiring.AddressPins {
+        if address & (1 << uint(bit)) != 0 {
+            value |= pinMask32(pin)
+        }
+    }
+    if a3 { value |= pinMask32(d.wiring.A3Pin) }
+    if a4 { value |= pinMask32(d.wiring.A4Pin) }
+    if flash { value |= pinMask32(d.wiring.FlashPin) }
+
+    setup := pinMask32(d.wiring.AddressPins[:]...) | pinMask32(d.wiring.A3Pin, d.wiring.A4Pin, d.wiring.FlashPin)
+    if err := d.writePins(setup, value) ; err != nil {
+        return 0, err
+    }
+
+    data := d.expanders[0]
+    if err := data.SetPortDirection(d.wiring.DataPort, 0xFF) ; err != nil {
+        return 0, err
+    }
+
+    var enable uint32
+    if display < len(d.wiring.ChipEnablePins) {
+        enable = pinMask32(d.wiring.ChipEnablePins[display])
+    }
+    lines := enable | pinMask32(d.wiring.ReadPin)
+
+    err := d.writePins(lines, 0)
+    var val byte
+    if err == nil {
+        val, err = data.ReadPort(d.wiring.DataPort)
+    }
+
+    // Always put /RD and the data port back, so that a failed read
+    // doesn't leave the display driving the bus.
+    //
+    if restore := d.writePins(lines, lines) ; err == nil {
+        err = restore
+    }
+    if restore := data.SetPortDirection(d.wiring.DataPort, 0x00) ; err == nil {
+        err = restore
+    }
+    return val, err
+}
+
+// Runs the self-test on every display, which shows a test pattern for
+// about five seconds, and returns whether each display passed. Without
+// /RD wired the test still runs, but only an error can be returned.
+//
+func (d *HDSP2111Display) SelfTest() ([]bool, error) {
+    if err := d.updateControl(HDSP2111_CONTROL_SELF_TEST, HDSP2111_CONTROL_SELF_TEST) ; err != nil {
+        return nil, err
+    }
+
+    // The display clears D6 itself when the test is done, so the
+    // cache is cleared to match.
+    //
+    for display := range d.control {
+        d.control[display] &^= HDSP2111_CONTROL_SELF_TEST
+    }
+
+    time.Sleep(HDSP2111_SELF_TEST_TIME)
+
+    passed := make([]bool, len(d.control))
+    for display := range d.control {
+        control, err := d.read(display, 0, false, true, true)
+        if err != nil {
+            return nil, err
+        }
+        passed[display] = control & HDSP2111_CONTROL_TEST_PASSED != 0
+    }
+
+    return passed, nil
+}
@@ -20,7 +20,6 @@ import (
     "fmt"
 
     "gobot.io/x/gobot/drivers/i2c"
-    "gobot.io/x/gobot/platforms/raspi"
 )
 
 // Constants
@@ -45,12 +44,17 @@ const (
     HT16K33_CMD_BRIGHTNESS byte = 0xE0
 )
 
+// HT16K33_MAX_BRIGHTNESS is the brightest of the 16 dimming levels.
+//
+const HT16K33_MAX_BRIGHTNESS int = 15
+
 type HT16K33Driver struct {
     name string
     address int
     connection i2c.Connection
     buffer []byte
     altIndex []int
+    blink byte
 }
 
 func NewHT16K33Driver(addr int) *HT16K33Driver {
@@ -65,27 +69,25 @@ func NewHT16K33Driver(addr int) *HT16K33Driver {
 func (driver *HT16K33Driver) Name() string { return driver.name }
 func (driver *HT16K33Driver) SetName(newName string ) { driver.name = newName }
 func (driver *HT16K33Driver) Connection() i2c.Connection { return driver.connection }
+func (driver *HT16K33Driver) Address() int { return driver.address }
 
 // Initializes and opens a connection to an HT16K33.
 // Returns the i2c.Connection on sucess, err on failure.
 //
 func (d *HT16K33Driver) Start() (err error) {
-    adapter := raspi.NewAdaptor()
-    adapter.Connect()
-    bus := adapter.GetDefaultBus()
-
-    // Check to see if the device actually is on the I2C buss.
-    // If it is then use it, else return an error.
-    //
-    if device, err := adapter.GetConnection(d.address, bus) ; err == nil {
-        if _, err := device.ReadByte() ; err == nil {
-            fmt.Printf(" Using device 0x%x / %d on bus %d\n", d.address, d.address, bus)
-        } else {
-            return fmt.Errorf(" Could not find device 0x%x / %d", d.address, d.address)
-        }
+    connection, err := openI2CConnection(d.address)
+    if err != nil {
+        return err
     }
 
-    d.connection, _ = adapter.GetConnection(d.address, bus)
+    return d.StartWithConnection(connection)
+}
+
+// Initializes the HT16K33 over a connection that has already been
+// opened, such as a SimulatedConnection or a device on another bus.
+//
+func (d *HT16K33Driver) StartWithConnection(connection i2c.Connection) (err error) {
+    d.connection = connection
 
     // Turn on chip's internal oscillator.
     //
@@ -102,6 +104,39 @@ func (d *HT16K33Driver) Start() (err error) {
     return nil
 }
 
+// Sets the display brightness, from 0 to HT16K33_MAX_BRIGHTNESS.
+// Even 0 is lit; use Clear to turn the LEDs off.
+//
+func (d *HT16K33Driver) SetBrightness(level int) error {
+    if level < 0 || level > HT16K33_MAX_BRIGHTNESS {
+        return fmt.Errorf(" Brightness %d is out of range 0-%d", level, HT16K33_MAX_BRIGHTNESS)
+    }
+    if d.connection == nil {
+        return fmt.Errorf(" %s is not started", d.name)
+    }
+
+    return d.connection.WriteByte(HT16K33_CMD_BRIGHTNESS | byte(level))
+}
+
+// Blinks the whole display, at HT16K33_BLINK_2HZ, HT16K33_BLINK_1HZ or
+// HT16K33_BLINK_HALFHZ, or stops it blinking with HT16K33_BLINK_OFF.
+//
+func (d *HT16K33Driver) SetBlinkRate(rate byte) error {
+    if rate &^ 0x06 != 0 {
+        return fmt.Errorf(" 0x%02x is not an HT16K33 blink rate", rate)
+    }
+    if d.connection == nil {
+        return fmt.Errorf(" %s is not started", d.name)
+    }
+
+    d.blink = rate
+    return d.connection.WriteByte(HT16K33_DISPLAY_SETUP | HT16K33_DISPLAY_ON | rate)
+}
+
+// The blink rate last set with SetBlinkRate.
+//
+func (d *HT16K33Driver) BlinkRate() byte { return d.blink }
+
 // Clear the device of all data, and in the process turn off
 // any LEDs that might be on.
 //
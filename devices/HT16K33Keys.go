@@ -0,0 +1,141 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "fmt"
+    "sync"
+    "time"
+)
+
+// The HT16K33's key scan. Keys wired across the ROW (KS) and COM (K)
+// lines are scanned by the chip itself, which debounces them over two
+// scans and sets a bit in key data RAM for each key held down. Reading
+// key data RAM clears it, so a key still held is seen again on the
+// next scan.
+//
+// Keys are numbered from 0 along K1, KS0 to KS12, then on along K2
+// and K3, for 39 keys in all.
+//
+const (
+    HT16K33_KEY_DATA byte = 0x40
+    HT16K33_INT_FLAG byte = 0x60
+    HT16K33_KEY_ROWS int = 3
+    HT16K33_KEY_COLUMNS int = 13
+    HT16K33_KEYS int = HT16K33_KEY_ROWS * HT16K33_KEY_COLUMNS
+)
+
+// DEFAULT_KEY_POLL_INTERVAL is how often WatchKeys reads key data RAM,
+// a little slower than the chip scans.
+//
+const DEFAULT_KEY_POLL_INTERVAL = 30 * time.Millisecond
+
+// Reads which keys are down, with key 0 in bit 0.
+//
+func (d *HT16K33Driver) ReadKeys() (keys uint64, err error) {
+    if d.connection == nil {
+        return 0, fmt.Errorf(" %s is not started", d.name)
+    }
+
+    for row := 0 ; row < HT16K33_KEY_ROWS ; row++ {
+        val, err := d.connection.ReadWordData(HT16K33_KEY_DATA + byte(row * 2))
+        if err != nil {
+            return 0, err
+        }
+        mask := uint16(1) << uint(HT16K33_KEY_COLUMNS) - 1
+        keys |= uint64(val & mask) << uint(row * HT16K33_KEY_COLUMNS)
+    }
+    return keys, nil
+}
+
+// A key going down or coming back up, as reported by WatchKeys.
+//
+type KeyEvent struct {
+    Key int
+    Pressed bool
+    Time time.Time
+}
+
+func (e KeyEvent) String() string {
+    state := "released"
+    if e.Pressed { state = "pressed" }
+
+    return fmt.Sprintf("%s K%d KS%d %s", e.Time.Format("15:04:05.000"),
+        e.Key / HT16K33_KEY_COLUMNS + 1, e.Key % HT16K33_KEY_COLUMNS, state)
+}
+
+// Polls the key scan every interval, or DEFAULT_KEY_POLL_INTERVAL if it
+// is zero, and publishes a KeyEvent on the returned channel each time a
+// key is pressed or released.
+//
+// Call stop to stop watching, which closes the channel.
+//
+func (d *HT16K33Driver) WatchKeys(interval time.Duration) (events <-chan KeyEvent, stop func(), err error) {
+    if interval == 0 { interval = DEFAULT_KEY_POLL_INTERVAL }
+
+    // Reading the keys also clears anything left over from before.
+    //
+    keys, err := d.ReadKeys()
+    if err != nil {
+        return nil, nil, err
+    }
+
+    channel := make(chan KeyEvent, 16)
+    done := make(chan struct{})
+
+    go func() {
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        defer close(channel)
+
+        for {
+            select {
+            case <-done:
+                return
+            case now := <-ticker.C:
+                // Errors reading the chip are not fatal; the next
+                // poll tries again.
+                //
+                latest, err := d.ReadKeys()
+                if err != nil {
+                    continue
+                }
+
+                for key := 0 ; key < HT16K33_KEYS ; key++ {
+                    bit := uint64(1) << uint(key)
+                    if (keys ^ latest) & bit == 0 {
+                        continue
+                    }
+
+                    select {
+                    case channel <- KeyEvent { Key: key, Pressed: latest & bit != 0, Time: now }:
+                    case <-done:
+                        return
+                    }
+                }
+                keys = latest
+            }
+        }
+    }()
+
+    var once sync.Once
+    stop = func() {
+        once.Do(func() { close(done) })
+    }
+
+    return channel, stop, nil
+}
@@ -0,0 +1,253 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "syscall"
+    "unsafe"
+
+    "gobot.io/x/gobot/drivers/i2c"
+    "gobot.io/x/gobot/platforms/raspi"
+    "gobot.io/x/gobot/sysfs"
+)
+
+// I2C_DEFAULT_BUS asks OpenI2CConnection for the Raspberry Pi's
+// default bus, 1 on everything but the earliest boards.
+//
+const I2C_DEFAULT_BUS int = -1
+
+// Opens a connection to a device on the default I2C bus.
+//
+func openI2CConnection(address int) (i2c.Connection, error) {
+    return OpenI2CConnection(I2C_DEFAULT_BUS, address)
+}
+
+// Opens a connection to a device on an I2C bus, or on the default bus
+// for I2C_DEFAULT_BUS. Drivers take it through StartWithConnection.
+//
+// Any /dev/i2c-* bus can be used, as I2CBuses lists them, not just the
+// 0 and 1 the raspi adaptor allows. Each connection has the bus device
+// to itself, so closing it closes nothing another driver is using.
+//
+// Check to see if the device actually is on the I2C buss by reading
+// a byte from it. If it is then use it, else return an error.
+//
+func OpenI2CConnection(bus int, address int) (i2c.Connection, error) {
+    if bus < 0 {
+        adapter := raspi.NewAdaptor()
+        adapter.Connect()
+        bus = adapter.GetDefaultBus()
+    }
+
+    busDevice, err := sysfs.NewI2cDevice(fmt.Sprintf("/dev/i2c-%d", bus))
+    if err != nil {
+        return nil, err
+    }
+    device := i2c.NewConnection(busDevice, address)
+
+    if _, err := device.ReadByte() ; err != nil {
+        device.Close()
+        return nil, fmt.Errorf(" Could not find device 0x%x / %d on bus %d", address, address, bus)
+    }
+
+    fmt.Printf(" Using device 0x%x / %d on bus %d\n", address, address, bus)
+    return device, nil
+}
+
+// Linux i2c-dev ioctls and SMBus transaction types, from
+// linux/i2c-dev.h and linux/i2c.h.
+//
+const (
+    i2cSlave = 0x0703
+    i2cFuncs = 0x0705
+    i2cSMBus = 0x0720
+
+    i2cFuncSMBusQuick = 0x00010000
+
+    i2cSMBusWrite = 0
+    i2cSMBusRead = 1
+
+    i2cSMBusQuick = 0
+    i2cSMBusByte = 1
+    i2cSMBusByteData = 2
+)
+
+// The data field is an unsafe.Pointer, not a uintptr, so that the
+// buffer it points to is kept alive, and moved with the stack, until
+// the ioctl is made.
+//
+type i2cSMBusIoctlData struct {
+    readWrite byte
+    command byte
+    size uint32
+    data unsafe.Pointer
+}
+
+// Lists the I2C buses Linux has, from /dev/i2c-*, in order.
+//
+func I2CBuses() ([]int, error) {
+    paths, err := filepath.Glob("/dev/i2c-*")
+    if err != nil {
+        return nil, err
+    }
+
+    var buses []int
+    for _, path := range paths {
+        if bus, err := strconv.Atoi(strings.TrimPrefix(path, "/dev/i2c-")) ; err == nil {
+            buses = append(buses, bus)
+        }
+    }
+
+    sort.Ints(buses)
+    return buses, nil
+}
+
+// A whole I2C bus, /dev/i2c-<number>, for probing every address on
+// it. Unlike an i2c.Connection it isn't tied to one address.
+//
+type LinuxI2CBus struct {
+    number int
+    file *os.File
+    functions uint64
+    mutex sync.Mutex
+}
+
+func OpenI2CBus(number int) (*LinuxI2CBus, error) {
+    file, err := os.OpenFile(fmt.Sprintf("/dev/i2c-%d", number), os.O_RDWR, 0)
+    if err != nil {
+        return nil, err
+    }
+
+    bus := &LinuxI2CBus { number: number, file: file }
+    if err := bus.ioctlPointer(i2cFuncs, unsafe.Pointer(&bus.functions)) ; err != nil {
+        file.Close()
+        return nil, err
+    }
+
+    return bus, nil
+}
+
+func (b *LinuxI2CBus) Number() int { return b.number }
+
+func (b *LinuxI2CBus) Close() error {
+    return b.file.Close()
+}
+
+func (b *LinuxI2CBus) ioctl(request uintptr, arg uintptr) error {
+    if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, b.file.Fd(), request, arg) ; errno != 0 {
+        return errno
+    }
+    return nil
+}
+
+// An ioctl that passes a pointer. The pointer is only converted to a
+// uintptr in the call to Syscall itself, as package unsafe requires.
+//
+func (b *LinuxI2CBus) ioctlPointer(request uintptr, arg unsafe.Pointer) error {
+    if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, b.file.Fd(), request, uintptr(arg)) ; errno != 0 {
+        return errno
+    }
+    return nil
+}
+
+func (b *LinuxI2CBus) smbus(address int, readWrite byte, command byte, size uint32, data *[34]byte) error {
+    if err := b.ioctl(i2cSlave, uintptr(address)) ; err != nil {
+        return err
+    }
+
+    args := i2cSMBusIoctlData {
+        readWrite: readWrite,
+        command: command,
+        size: size,
+    }
+    if data != nil {
+        args.data = unsafe.Pointer(data)
+    }
+
+    return b.ioctlPointer(i2cSMBus, unsafe.Pointer(&args))
+}
+
+// Probes one address, the way i2cdetect does. A quick write is an
+// address and a write bit with no data, which no chip acts on but some
+// EEPROMs can be upset by; a read is safe for those but can upset write
+// only chips. Adapters that can't do quick writes are always read.
+//
+func (b *LinuxI2CBus) Probe(address int, method ProbeMethod) (AddressState, error) {
+    b.mutex.Lock()
+    defer b.mutex.Unlock()
+
+    if method == PROBE_QUICK && b.functions & i2cFuncSMBusQuick == 0 {
+        method = PROBE_READ
+    }
+
+    var err error
+    if method == PROBE_QUICK {
+        err = b.smbus(address, i2cSMBusWrite, 0, i2cSMBusQuick, nil)
+    } else {
+        var data [34]byte
+        err = b.smbus(address, i2cSMBusRead, 0, i2cSMBusByte, &data)
+    }
+
+    // A kernel driver has claimed the address, so there is certainly
+    // a chip there, but it isn't ours to probe.
+    //
+    if err == syscall.EBUSY {
+        return ADDRESS_BUSY, nil
+    }
+    if err != nil {
+        return ADDRESS_EMPTY, nil
+    }
+    return ADDRESS_FOUND, nil
+}
+
+// Returns a connection to one address on the bus, for identifying the
+// chip there. It shares the bus's file, so closing it does nothing.
+//
+func (b *LinuxI2CBus) Connection(address int) (RegisterConnection, error) {
+    return &linuxI2CDevice { bus: b, address: address }, nil
+}
+
+type linuxI2CDevice struct {
+    bus *LinuxI2CBus
+    address int
+}
+
+func (d *linuxI2CDevice) ReadByteData(reg uint8) (uint8, error) {
+    d.bus.mutex.Lock()
+    defer d.bus.mutex.Unlock()
+
+    var data [34]byte
+    err := d.bus.smbus(d.address, i2cSMBusRead, reg, i2cSMBusByteData, &data)
+    return data[0], err
+}
+
+func (d *linuxI2CDevice) WriteByteData(reg uint8, val uint8) error {
+    d.bus.mutex.Lock()
+    defer d.bus.mutex.Unlock()
+
+    data := [34]byte{ val }
+    return d.bus.smbus(d.address, i2cSMBusWrite, reg, i2cSMBusByteData, &data)
+}
+
+func (d *linuxI2CDevice) Close() error { return nil }
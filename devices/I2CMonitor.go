@@ -0,0 +1,229 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "fmt"
+    "sort"
+    "sync"
+    "time"
+)
+
+// DEFAULT_SCAN_INTERVAL is a good interval for Watch, often enough to
+// notice a device being plugged in without keeping the bus busy.
+//
+const DEFAULT_SCAN_INTERVAL = 2 * time.Second
+
+// Whether a device appeared on or disappeared from a bus.
+//
+type I2CEventKind int
+
+const (
+    DEVICE_ADDED I2CEventKind = iota
+    DEVICE_REMOVED
+)
+
+func (k I2CEventKind) String() string {
+    if k == DEVICE_ADDED { return "added" }
+    return "removed"
+}
+
+func (k I2CEventKind) MarshalText() ([]byte, error) {
+    return []byte(k.String()), nil
+}
+
+// A device appearing or disappearing between two scans.
+//
+type I2CEvent struct {
+    Kind I2CEventKind `json:"event"`
+    Device DetectedDevice `json:"device"`
+    Time time.Time `json:"time"`
+}
+
+func (e I2CEvent) String() string {
+    return fmt.Sprintf("%s %-7s %s: %s", e.Time.Format("15:04:05.000"), e.Kind, e.Device, e.Device.Description())
+}
+
+// Reports whether the device is, or could be, the named part.
+//
+func (d DetectedDevice) MaybePart(name string) bool {
+    if d.Model == name {
+        return true
+    }
+    for _, candidate := range d.Candidates {
+        if candidate == name {
+            return true
+        }
+    }
+    return false
+}
+
+type deviceKey struct {
+    bus int
+    address int
+}
+
+// Rescans every interval, which must be positive, and publishes an I2CEvent on the returned
+// channel for each device that appears or disappears. Devices already
+// there when watching starts are reported as added by the first scan,
+// so a watcher sees every device it should act on.
+//
+// Only new devices are identified, so identification probes run once
+// per device rather than on every scan. When Buses is empty, the list
+// of buses is read again for every scan, and a bus going away removes
+// its devices.
+//
+// Call stop to stop watching. The channel is closed.
+//
+func (s *I2CScanner) Watch(interval time.Duration) (events <-chan I2CEvent, stop func(), err error) {
+    if interval <= 0 {
+        return nil, nil, fmt.Errorf(" Scan interval %s is not positive", interval)
+    }
+
+    channel := make(chan I2CEvent, 16)
+    done := make(chan struct{})
+    watcher := &i2cWatcher {
+        scanner: s,
+        interval: interval,
+        present: map[deviceKey]DetectedDevice{},
+        events: channel,
+        done: done,
+    }
+
+    go watcher.run()
+
+    var once sync.Once
+    stop = func() {
+        once.Do(func() { close(done) })
+    }
+
+    return channel, stop, nil
+}
+
+type i2cWatcher struct {
+    scanner *I2CScanner
+    interval time.Duration
+    present map[deviceKey]DetectedDevice
+    events chan I2CEvent
+    done chan struct{}
+}
+
+func (w *i2cWatcher) run() {
+    ticker := time.NewTicker(w.interval)
+    defer ticker.Stop()
+    defer close(w.events)
+
+    for {
+        if !w.check() {
+            return
+        }
+
+        select {
+        case <-w.done:
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// Scans every bus once and publishes the differences from the last
+// scan. Returns false once watching has stopped.
+//
+func (w *i2cWatcher) check() bool {
+    now := time.Now()
+    seen := map[deviceKey]bool{}
+
+    buses := w.scanner.Buses
+    if len(buses) == 0 {
+        buses, _ = I2CBuses()
+    }
+
+    var added []DetectedDevice
+    for _, number := range buses {
+        bus, err := w.scanner.Open(number)
+        if err != nil {
+            continue
+        }
+
+        scanner := *w.scanner
+        scanner.Identify = false
+        scan, err := scanner.ScanBus(bus)
+
+        // A bus that fails part way keeps what it had, rather than
+        // reporting every device removed and then added again.
+        //
+        if err != nil {
+            for key := range w.present {
+                if key.bus == number { seen[key] = true }
+            }
+            bus.Close()
+            continue
+        }
+
+        for _, device := range scan.Devices {
+            key := deviceKey{ device.Bus, device.Address }
+            seen[key] = true
+            if _, ok := w.present[key] ; ok {
+                continue
+            }
+
+            if w.scanner.Identify {
+                IdentifyDevice(bus, &device)
+            }
+            w.present[key] = device
+            added = append(added, device)
+        }
+        bus.Close()
+    }
+
+    var removed []DetectedDevice
+    for key, device := range w.present {
+        if !seen[key] {
+            delete(w.present, key)
+            removed = append(removed, device)
+        }
+    }
+    sort.Slice(removed, func(i, j int) bool {
+        if removed[i].Bus != removed[j].Bus {
+            return removed[i].Bus < removed[j].Bus
+        }
+        return removed[i].Address < removed[j].Address
+    })
+
+    for _, device := range removed {
+        if !w.publish(I2CEvent { Kind: DEVICE_REMOVED, Device: device, Time: now }) {
+            return false
+        }
+    }
+
+    for _, device := range added {
+        if !w.publish(I2CEvent { Kind: DEVICE_ADDED, Device: device, Time: now }) {
+            return false
+        }
+    }
+
+    return true
+}
+
+func (w *i2cWatcher) publish(event I2CEvent) bool {
+    select {
+    case w.events <- event:
+        return true
+    case <-w.done:
+        return false
+    }
+}
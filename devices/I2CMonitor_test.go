@@ -0,0 +1,135 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestI2CScannerWatchInterval(t *testing.T) {
+    for _, interval := range []time.Duration { 0, -time.Second } {
+        events, stop, err := NewI2CScanner().Watch(interval)
+        if err == nil {
+            stop()
+            t.Errorf("watched every %s", interval)
+        }
+        if events != nil || stop != nil {
+            t.Errorf("an interval of %s returned a channel or stop", interval)
+        }
+    }
+}
+
+// A simulated bus whose probes fail from an address on, while failing
+// is set, as a bus does when it is unplugged part way through a scan.
+//
+type failingI2CBus struct {
+    *SimulatedI2CBus
+    failing bool
+    failAt int
+}
+
+func (b *failingI2CBus) Probe(address int, method ProbeMethod) (AddressState, error) {
+    if b.failing && address >= b.failAt {
+        return ADDRESS_EMPTY, fmt.Errorf(" Simulated bus %d failed at 0x%02x", b.Number(), address)
+    }
+    return b.SimulatedI2CBus.Probe(address, method)
+}
+
+func TestI2CWatcherCheck(t *testing.T) {
+    one := &failingI2CBus { SimulatedI2CBus: NewSimulatedI2CBus(1), failAt: 0x50 }
+    three := NewSimulatedI2CBus(3)
+
+    scanner := NewI2CScanner()
+    scanner.Buses = []int{ 1, 3 }
+    scanner.Identify = false
+    scanner.Open = func(number int) (I2CBus, error) {
+        if number == 1 { return one, nil }
+        return three, nil
+    }
+
+    watcher := &i2cWatcher {
+        scanner: scanner,
+        present: map[deviceKey]DetectedDevice{},
+        events: make(chan I2CEvent, 16),
+        done: make(chan struct{}),
+    }
+
+    // Runs one check and compares the events it published, in order.
+    //
+    expect := func(step string, want ...I2CEvent) {
+        if !watcher.check() {
+            t.Fatalf("%s: the check stopped", step)
+        }
+        var got []I2CEvent
+        for len(watcher.events) > 0 {
+            got = append(got, <-watcher.events)
+        }
+
+        if len(got) != len(want) {
+            t.Errorf("%s: got %d events, not %d: %v", step, len(got), len(want), got)
+            return
+        }
+        for i := range want {
+            if got[i].Kind != want[i].Kind || got[i].Device.Bus != want[i].Device.Bus || got[i].Device.Address != want[i].Device.Address {
+                t.Errorf("%s: event %d is %s %s, not %s %s", step, i,
+                    got[i].Kind, got[i].Device, want[i].Kind, want[i].Device)
+            }
+        }
+    }
+    event := func(kind I2CEventKind, bus int, address int) I2CEvent {
+        return I2CEvent { Kind: kind, Device: DetectedDevice { Bus: bus, Address: address } }
+    }
+
+    one.Attach(0x20, NewSimulatedConnection())
+    one.Attach(0x70, NewSimulatedConnection())
+    three.Attach(0x40, NewSimulatedConnection())
+
+    // Devices already there are added by the first scan, in bus and
+    // address order, and not again after that.
+    //
+    expect("first scan",
+        event(DEVICE_ADDED, 1, 0x20),
+        event(DEVICE_ADDED, 1, 0x70),
+        event(DEVICE_ADDED, 3, 0x40))
+    expect("unchanged")
+
+    // Removals are published before additions.
+    //
+    one.Detach(0x20)
+    three.Attach(0x41, NewSimulatedConnection())
+    expect("detach and attach",
+        event(DEVICE_REMOVED, 1, 0x20),
+        event(DEVICE_ADDED, 3, 0x41))
+
+    // A bus that fails part way keeps its devices, even one that has
+    // gone, while the other buses are still watched.
+    //
+    one.failing = true
+    one.Detach(0x70)
+    three.Detach(0x40)
+    expect("failed scan",
+        event(DEVICE_REMOVED, 3, 0x40))
+
+    // Once it recovers, what went while it was failing is removed.
+    //
+    one.failing = false
+    expect("recovered",
+        event(DEVICE_REMOVED, 1, 0x70))
+    expect("unchanged again")
+}
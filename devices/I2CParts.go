@@ -0,0 +1,242 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "sync"
+)
+
+// A part that can be found on an I2C bus.
+//
+// Addresses lists every address the part can be strapped to. Identify,
+// if set, checks whether the chip at an address really is this part.
+// It must be safe to run against any other chip that can share the
+// address: reading ID registers is fine, writing anything that changes
+// what a chip does is not. Parts without a safe check leave it nil, and
+// are only ever reported as candidates.
+//
+type I2CPart struct {
+    Name string
+    Description string
+    Addresses []int
+    Identify func(connection RegisterConnection) (bool, error)
+}
+
+// Returns the addresses first to last inclusive.
+//
+func addressRange(first, last int) []int {
+    var addresses []int
+    for address := first ; address <= last ; address++ {
+        addresses = append(addresses, address)
+    }
+    return addresses
+}
+
+// Identifies a chip by reading an ID register and comparing it.
+//
+func identifyByRegister(reg uint8, ids ...uint8) func(RegisterConnection) (bool, error) {
+    return func(connection RegisterConnection) (bool, error) {
+        val, err := connection.ReadByteData(reg)
+        if err != nil {
+            return false, err
+        }
+
+        for _, id := range ids {
+            if val == id {
+                return true, nil
+            }
+        }
+        return false, nil
+    }
+}
+
+// Identifies an MCP23017 in bank 0, where IOCON appears at both 0x0A
+// and 0x0B. The value is first compared at both addresses, then the
+// HAEN bit, which does nothing on the I2C part, is flipped through
+// 0x0B and looked for at 0x0A before IOCON is put back.
+//
+// Nothing but 0x0B is ever written, and whatever is read back, it is
+// always given back the value it held. On an MCP23008, where 0x0A is
+// the output latch, 0x0B doesn't exist and the writes are ignored.
+//
+func identifyMCP23017(connection RegisterConnection) (bool, error) {
+    iocon, err := connection.ReadByteData(MCP23017_IOCON)
+    if err != nil {
+        return false, err
+    }
+    mirror, err := connection.ReadByteData(MCP23017_IOCON + 1)
+    if err != nil {
+        return false, err
+    }
+    if mirror != iocon || iocon & MCP23017_IOCON_BANK != 0 {
+        return false, nil
+    }
+
+    err = connection.WriteByteData(MCP23017_IOCON + 1, iocon ^ MCP23017_IOCON_HAEN)
+    changed, readErr := connection.ReadByteData(MCP23017_IOCON)
+    if restoreErr := connection.WriteByteData(MCP23017_IOCON + 1, iocon) ; restoreErr != nil {
+        return false, restoreErr
+    }
+    if err != nil {
+        return false, err
+    }
+    if readErr != nil {
+        return false, readErr
+    }
+
+    return changed == iocon ^ MCP23017_IOCON_HAEN, nil
+}
+
+var (
+    i2cPartsMutex sync.Mutex
+    i2cParts = []I2CPart {
+        {
+            Name: "MCP23017",
+            Description: "16-bit port expander",
+            Addresses: addressRange(0x20, 0x27),
+            Identify: identifyMCP23017,
+        },
+        {
+            Name: "MCP23008",
+            Description: "8-bit port expander",
+            Addresses: addressRange(0x20, 0x27),
+        },
+        {
+            Name: "BNO055",
+            Description: "9-axis absolute orientation sensor",
+            Addresses: []int{ 0x28, 0x29 },
+            Identify: identifyByRegister(BNO055_CHIP_ID, BNO055_ID),
+        },
+        {
+            Name: "SSD1306",
+            Description: "128x64 or 128x32 OLED display",
+            Addresses: []int{ 0x3C, 0x3D },
+        },
+        {
+            Name: "PCA9685",
+            Description: "16 channel PWM driver",
+            Addresses: addressRange(0x40, 0x7F),
+        },
+        {
+            Name: "ADS1115",
+            Description: "4 channel 16-bit ADC",
+            Addresses: addressRange(0x48, 0x4B),
+        },
+        {
+            Name: "MPU6050",
+            Description: "6-axis accelerometer and gyroscope",
+            Addresses: []int{ 0x68, 0x69 },
+            Identify: identifyByRegister(0x75, 0x68),
+        },
+        {
+            Name: "DS3231",
+            Description: "real time clock",
+            Addresses: []int{ 0x68 },
+        },
+        {
+            Name: "HT16K33",
+            Description: "LED matrix driver, on Adafruit's LED backpacks and FeatherWings",
+            Addresses: addressRange(0x70, 0x77),
+        },
+        {
+            Name: "TCA9548A",
+            Description: "8 channel I2C multiplexer",
+            Addresses: addressRange(0x70, 0x77),
+        },
+        {
+            Name: "BME280",
+            Description: "temperature, humidity and pressure sensor",
+            Addresses: []int{ 0x76, 0x77 },
+            Identify: identifyByRegister(0xD0, 0x60),
+        },
+        {
+            Name: "BMP280",
+            Description: "temperature and pressure sensor",
+            Addresses: []int{ 0x76, 0x77 },
+            Identify: identifyByRegister(0xD0, 0x56, 0x57, 0x58),
+        },
+    }
+)
+
+// Adds a part to the table IdentifyDevice consults. Parts added later
+// are tried after the built in ones.
+//
+func RegisterI2CPart(part I2CPart) {
+    i2cPartsMutex.Lock()
+    defer i2cPartsMutex.Unlock()
+
+    i2cParts = append(i2cParts, part)
+}
+
+// Returns the known parts that can be at an address.
+//
+func I2CPartsAt(address int) []I2CPart {
+    i2cPartsMutex.Lock()
+    defer i2cPartsMutex.Unlock()
+
+    var parts []I2CPart
+    for _, part := range i2cParts {
+        for _, partAddress := range part.Addresses {
+            if partAddress == address {
+                parts = append(parts, part)
+                break
+            }
+        }
+    }
+    return parts
+}
+
+// Works out what a detected device is from the parts known at its
+// address. The first part whose Identify confirms it becomes the
+// Model. Otherwise the parts without an Identify, along with any whose
+// Identify couldn't run, are the Candidates. Busy devices belong to a
+// kernel driver and aren't probed.
+//
+func IdentifyDevice(bus I2CBus, device *DetectedDevice) error {
+    device.Model = ""
+    device.Candidates = nil
+
+    parts := I2CPartsAt(device.Address)
+
+    var connection RegisterConnection
+    if !device.Busy {
+        var err error
+        if connection, err = bus.Connection(device.Address) ; err != nil {
+            return err
+        }
+    }
+
+    for _, part := range parts {
+        if part.Identify == nil || connection == nil {
+            device.Candidates = append(device.Candidates, part.Name)
+            continue
+        }
+
+        confirmed, err := part.Identify(connection)
+        if err != nil {
+            device.Candidates = append(device.Candidates, part.Name)
+            continue
+        }
+        if confirmed {
+            device.Model = part.Name
+            device.Candidates = nil
+            return nil
+        }
+    }
+
+    return nil
+}
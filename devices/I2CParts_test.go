@@ -0,0 +1,90 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "fmt"
+    "testing"
+)
+
+// A connection whose reads of one register fail after the first few,
+// as when a chip stops answering part way through being identified.
+//
+type failingConnection struct {
+    *SimulatedConnection
+    reg uint8
+    reads int
+}
+
+func (c *failingConnection) ReadByteData(reg uint8) (uint8, error) {
+    if reg == c.reg {
+        if c.reads == 0 {
+            return 0, fmt.Errorf(" No answer reading 0x%02x", reg)
+        }
+        c.reads--
+    }
+    return c.SimulatedConnection.ReadByteData(reg)
+}
+
+func TestIdentifyByRegister(t *testing.T) {
+    identify := identifyByRegister(0x75, 0x68)
+    sim := NewSimulatedConnection()
+
+    sim.SetRegister(0x75, 0x68)
+    if confirmed, err := identify(sim) ; err != nil || !confirmed {
+        t.Errorf("ID 68 was identified as %v, %v", confirmed, err)
+    }
+    sim.SetRegister(0x75, 0x70)
+    if confirmed, err := identify(sim) ; err != nil || confirmed {
+        t.Errorf("ID 70 was identified as %v, %v", confirmed, err)
+    }
+    if _, err := identify(&failingConnection { sim, 0x75, 0 }) ; err == nil {
+        t.Error("a failed read was not returned")
+    }
+}
+
+func TestIdentifyMCP23017(t *testing.T) {
+    sim := NewSimulatedMCP23017()
+    sim.SetRegister(MCP23017_IOCON, MCP23017_IOCON_MIRROR)
+
+    if confirmed, err := identifyMCP23017(sim) ; err != nil || !confirmed {
+        t.Errorf("an MCP23017 was identified as %v, %v", confirmed, err)
+    }
+    if iocon := sim.Register(MCP23017_IOCON) ; iocon != MCP23017_IOCON_MIRROR {
+        t.Errorf("IOCON is %02x after identifying, not %02x", iocon, MCP23017_IOCON_MIRROR)
+    }
+
+    // Another chip, whose 0x0B is a plain register, gets it back.
+    //
+    other := NewSimulatedConnection()
+    if confirmed, err := identifyMCP23017(other) ; err != nil || confirmed {
+        t.Errorf("a plain register file was identified as %v, %v", confirmed, err)
+    }
+    if val := other.Register(MCP23017_IOCON + 1) ; val != 0 {
+        t.Errorf("0x0B is %02x after identifying, not restored to 00", val)
+    }
+
+    // So does one that stops answering before the read back.
+    //
+    failing := &failingConnection { NewSimulatedConnection(), MCP23017_IOCON, 1 }
+    if _, err := identifyMCP23017(failing) ; err == nil {
+        t.Error("a failed read was not returned")
+    }
+    if val := failing.Register(MCP23017_IOCON + 1) ; val != 0 {
+        t.Errorf("0x0B is %02x after a failed read, not restored to 00", val)
+    }
+}
@@ -0,0 +1,320 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "fmt"
+    "strings"
+    "sync"
+)
+
+// I2C_ADDRESSES is the number of 7-bit I2C addresses.
+//
+const I2C_ADDRESSES int = 128
+
+// The addresses a scan covers by default. 0x00-0x02 and 0x78-0x7F
+// are reserved by the I2C specification for general call, CBUS,
+// high speed mode and 10-bit addressing.
+//
+const (
+    I2C_FIRST_ADDRESS int = 0x03
+    I2C_LAST_ADDRESS int = 0x77
+)
+
+// How an address is probed for a chip.
+//
+// PROBE_AUTO follows i2cdetect: reads for 0x30-0x37 and 0x50-0x5F,
+// where EEPROMs live, and quick writes everywhere else.
+//
+type ProbeMethod int
+
+const (
+    PROBE_AUTO ProbeMethod = iota
+    PROBE_QUICK
+    PROBE_READ
+)
+
+func (m ProbeMethod) String() string {
+    switch m {
+    case PROBE_QUICK:
+        return "quick"
+    case PROBE_READ:
+        return "read"
+    }
+    return "auto"
+}
+
+// What a scan found at an address.
+//
+type AddressState int
+
+const (
+    ADDRESS_SKIPPED AddressState = iota
+    ADDRESS_EMPTY
+    ADDRESS_FOUND
+    ADDRESS_BUSY
+)
+
+// The probe method to use for a range of addresses, first to last
+// inclusive.
+//
+type ProbeRange struct {
+    First int
+    Last int
+    Method ProbeMethod
+}
+
+// A bus that can be probed address by address. LinuxI2CBus is the
+// real thing, SimulatedI2CBus a stand in. Connection returns a
+// connection to one address, for identifying the chip there.
+//
+type I2CBus interface {
+    Number() int
+    Probe(address int, method ProbeMethod) (AddressState, error)
+    Connection(address int) (RegisterConnection, error)
+    Close() error
+}
+
+// A chip found by a scan. Busy means a kernel driver has claimed it.
+//
+// Model is the part an identification probe confirmed, if any.
+// Otherwise Candidates lists the known parts that can be at the
+// address, and that no probe ruled out.
+//
+type DetectedDevice struct {
+    Bus int `json:"bus"`
+    Address int `json:"address"`
+    Busy bool `json:"busy,omitempty"`
+    Model string `json:"model,omitempty"`
+    Candidates []string `json:"candidates,omitempty"`
+}
+
+func (d DetectedDevice) String() string {
+    return fmt.Sprintf("0x%02x / %d on I2C bus %d", d.Address, d.Address, d.Bus)
+}
+
+// Describes what the device probably is, for printing.
+//
+func (d DetectedDevice) Description() string {
+    if len(d.Model) > 0 {
+        return d.Model
+    }
+    if len(d.Candidates) > 0 {
+        return strings.Join(d.Candidates, " or ") + "?"
+    }
+    return "unknown"
+}
+
+// The result of scanning one bus. States holds what was found at every
+// address, for drawing a grid; Devices lists only the addresses that
+// answered.
+//
+type I2CBusScan struct {
+    Bus int `json:"bus"`
+    States [I2C_ADDRESSES]AddressState `json:"-"`
+    Devices []DetectedDevice `json:"devices"`
+}
+
+// Scans I2C buses for chips.
+//
+// Buses lists the buses to scan; when empty every /dev/i2c-* bus is.
+// First and Last bound the addresses probed. Ranges pick the probe
+// method for particular addresses, and Method is used for the rest.
+//
+// With Identify set, each device found that isn't busy is identified
+// with IdentifyDevice.
+//
+// Open opens a bus by number. It defaults to OpenI2CBus, and can be
+// replaced to scan SimulatedI2CBuses.
+//
+type I2CScanner struct {
+    Buses []int
+    First int
+    Last int
+    Method ProbeMethod
+    Ranges []ProbeRange
+    Identify bool
+    Open func(bus int) (I2CBus, error)
+}
+
+func NewI2CScanner() *I2CScanner {
+    scanner := &I2CScanner {
+        First: I2C_FIRST_ADDRESS,
+        Last: I2C_LAST_ADDRESS,
+        Method: PROBE_AUTO,
+        Identify: true,
+        Open: func(bus int) (I2CBus, error) {
+            return OpenI2CBus(bus)
+        },
+    }
+
+    return scanner
+}
+
+// Returns the probe method for an address.
+//
+func (s *I2CScanner) MethodFor(address int) ProbeMethod {
+    method := s.Method
+    for _, r := range s.Ranges {
+        if address >= r.First && address <= r.Last {
+            method = r.Method
+        }
+    }
+
+    if method != PROBE_AUTO {
+        return method
+    }
+    if address >= 0x30 && address <= 0x37 || address >= 0x50 && address <= 0x5F {
+        return PROBE_READ
+    }
+    return PROBE_QUICK
+}
+
+// Scans every bus, in order.
+//
+func (s *I2CScanner) Scan() ([]I2CBusScan, error) {
+    buses := s.Buses
+    if len(buses) == 0 {
+        var err error
+        if buses, err = I2CBuses() ; err != nil {
+            return nil, err
+        }
+        if len(buses) == 0 {
+            return nil, fmt.Errorf(" No I2C buses found in /dev")
+        }
+    }
+
+    var scans []I2CBusScan
+    for _, number := range buses {
+        bus, err := s.Open(number)
+        if err != nil {
+            return scans, err
+        }
+
+        scan, err := s.ScanBus(bus)
+        bus.Close()
+        if err != nil {
+            return scans, err
+        }
+        scans = append(scans, scan)
+    }
+
+    return scans, nil
+}
+
+// Scans a bus that is already open.
+//
+func (s *I2CScanner) ScanBus(bus I2CBus) (I2CBusScan, error) {
+    scan := I2CBusScan { Bus: bus.Number() }
+
+    first, last := s.First, s.Last
+    if first < 0 { first = 0 }
+    if last >= I2C_ADDRESSES { last = I2C_ADDRESSES - 1 }
+
+    for address := first ; address <= last ; address++ {
+        state, err := bus.Probe(address, s.MethodFor(address))
+        if err != nil {
+            return scan, err
+        }
+
+        scan.States[address] = state
+        if state != ADDRESS_FOUND && state != ADDRESS_BUSY {
+            continue
+        }
+
+        device := DetectedDevice {
+            Bus: scan.Bus,
+            Address: address,
+            Busy: state == ADDRESS_BUSY,
+        }
+
+        if s.Identify {
+            if err := IdentifyDevice(bus, &device) ; err != nil {
+                return scan, err
+            }
+        }
+        scan.Devices = append(scan.Devices, device)
+    }
+
+    return scan, nil
+}
+
+// A simulated I2C bus, for scanning without hardware. Devices maps
+// addresses to the chips on the bus; Probes records every probe.
+// Chips can be attached and detached while the bus is in use.
+//
+type SimulatedI2CBus struct {
+    number int
+    devices map[int]*SimulatedConnection
+    Probes []ProbeMethod
+    mutex sync.Mutex
+}
+
+func NewSimulatedI2CBus(number int) *SimulatedI2CBus {
+    return &SimulatedI2CBus {
+        number: number,
+        devices: map[int]*SimulatedConnection{},
+    }
+}
+
+func (b *SimulatedI2CBus) Number() int { return b.number }
+func (b *SimulatedI2CBus) Close() error { return nil }
+
+// Attaches a chip at an address, replacing any already there.
+//
+func (b *SimulatedI2CBus) Attach(address int, device *SimulatedConnection) {
+    b.mutex.Lock()
+    defer b.mutex.Unlock()
+
+    b.devices[address] = device
+}
+
+// Detaches the chip at an address.
+//
+func (b *SimulatedI2CBus) Detach(address int) {
+    b.mutex.Lock()
+    defer b.mutex.Unlock()
+
+    delete(b.devices, address)
+}
+
+// Returns the chip at an address, or nil.
+//
+func (b *SimulatedI2CBus) Device(address int) *SimulatedConnection {
+    b.mutex.Lock()
+    defer b.mutex.Unlock()
+
+    return b.devices[address]
+}
+
+func (b *SimulatedI2CBus) Connection(address int) (RegisterConnection, error) {
+    if device := b.Device(address) ; device != nil {
+        return device, nil
+    }
+    return nil, fmt.Errorf(" No device at 0x%02x on simulated bus %d", address, b.number)
+}
+
+func (b *SimulatedI2CBus) Probe(address int, method ProbeMethod) (AddressState, error) {
+    b.mutex.Lock()
+    defer b.mutex.Unlock()
+
+    b.Probes = append(b.Probes, method)
+    if _, ok := b.devices[address] ; ok {
+        return ADDRESS_FOUND, nil
+    }
+    return ADDRESS_EMPTY, nil
+}
@@ -0,0 +1,100 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "image/color"
+)
+
+// The colors a bicolor LED can show. Yellow is both the red and
+// green LEDs lit together.
+//
+// Values match Adafruit's LED_OFF, LED_RED, LED_YELLOW and LED_GREEN in
+// https://github.com/adafruit/Adafruit_LED_Backpack/
+//
+type LedColor byte
+
+const (
+    LED_OFF LedColor = iota
+    LED_RED
+    LED_YELLOW
+    LED_GREEN
+)
+
+func (c LedColor) String() string {
+    switch c {
+    case LED_OFF:
+        return "off"
+    case LED_RED:
+        return "red"
+    case LED_YELLOW:
+        return "yellow"
+    case LED_GREEN:
+        return "green"
+    }
+    return "unknown"
+}
+
+// Implements color.Color, so LED colors can be passed anywhere a
+// color is expected.
+//
+func (c LedColor) RGBA() (r, g, b, a uint32) {
+    if c.red() { r = 0xffff }
+    if c.green() { g = 0xffff }
+    return r, g, 0, 0xffff
+}
+
+// Picks the LED color closest to any other color. White and other
+// colors with strong red and green components come out yellow.
+//
+func ToLedColor(c color.Color) LedColor {
+    if led, ok := c.(LedColor); ok {
+        return led
+    }
+
+    r, g, _, a := c.RGBA()
+    if a == 0 {
+        return LED_OFF
+    }
+    return ledColorOf(r >= 0x8000, g >= 0x8000)
+}
+
+func (c LedColor) red() bool { return c == LED_RED || c == LED_YELLOW }
+func (c LedColor) green() bool { return c == LED_GREEN || c == LED_YELLOW }
+
+// Builds a color from the state of a red and a green LED.
+//
+func ledColorOf(red, green bool) LedColor {
+    switch {
+    case red && green:
+        return LED_YELLOW
+    case red:
+        return LED_RED
+    case green:
+        return LED_GREEN
+    }
+    return LED_OFF
+}
+
+// Sets or clears a bit in a byte.
+//
+func setBit(val byte, bit uint, on bool) byte {
+    if on {
+        return val | 1 << bit
+    }
+    return val &^ (1 << bit)
+}
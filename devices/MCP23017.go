@@ -0,0 +1,492 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "fmt"
+    "sync"
+)
+
+// MCP23017_DEFAULT_ADDRESS is the lowest I2C address an MCP23017
+// can be strapped to. A0-A2 select addresses up to 0x27.
+//
+const MCP23017_DEFAULT_ADDRESS int = 0x20
+
+// MCP23017 register addresses in bank 0 (IOCON.BANK = 0), where the
+// A and B registers of each pair are next to one another.
+//
+const (
+    MCP23017_IODIRA byte = 0x00
+    MCP23017_IODIRB byte = 0x01
+    MCP23017_IPOLA byte = 0x02
+    MCP23017_IPOLB byte = 0x03
+    MCP23017_GPINTENA byte = 0x04
+    MCP23017_GPINTENB byte = 0x05
+    MCP23017_DEFVALA byte = 0x06
+    MCP23017_DEFVALB byte = 0x07
+    MCP23017_INTCONA byte = 0x08
+    MCP23017_INTCONB byte = 0x09
+    MCP23017_IOCON byte = 0x0A
+    MCP23017_GPPUA byte = 0x0C
+    MCP23017_GPPUB byte = 0x0D
+    MCP23017_INTFA byte = 0x0E
+    MCP23017_INTFB byte = 0x0F
+    MCP23017_INTCAPA byte = 0x10
+    MCP23017_INTCAPB byte = 0x11
+    MCP23017_GPIOA byte = 0x12
+    MCP23017_GPIOB byte = 0x13
+    MCP23017_OLATA byte = 0x14
+    MCP23017_OLATB byte = 0x15
+)
+
+// Bits in the IOCON configuration register.
+//
+const (
+    MCP23017_IOCON_BANK byte = 0x80
+    MCP23017_IOCON_MIRROR byte = 0x40
+    MCP23017_IOCON_SEQOP byte = 0x20
+    MCP23017_IOCON_DISSLW byte = 0x10
+    MCP23017_IOCON_HAEN byte = 0x08
+    MCP23017_IOCON_ODR byte = 0x04
+    MCP23017_IOCON_INTPOL byte = 0x02
+)
+
+// The two eight bit ports of a port expander.
+//
+type Port int
+
+const (
+    PORT_A Port = iota
+    PORT_B
+)
+
+func (p Port) String() string {
+    if p == PORT_A { return "A" }
+    return "B"
+}
+
+// How a port expander pin is used.
+//
+type PinMode int
+
+const (
+    PIN_INPUT PinMode = iota
+    PIN_OUTPUT
+    PIN_INPUT_PULLUP
+)
+
+// MCP23017_PINS is the number of GPIO pins. Pins 0-7 are GPA0-GPA7,
+// pins 8-15 are GPB0-GPB7.
+//
+const MCP23017_PINS int = 16
+
+// A driver for the MCP23017 16-bit I2C port expander, and through
+// PortExpander.go and MCP23S17.go the rest of the family: the 8-bit
+// MCP23008 and the SPI MCP23S17.
+//
+// The output latches are cached, so setting a single output pin is one
+// register write rather than a read and a write. The cache is loaded
+// from the chip when the driver starts, and only goes stale if something
+// other than this driver writes the latches.
+//
+type MCP23017Driver struct {
+    name string
+    address int
+    ports int
+    ioconFixed byte
+    open func() (RegisterConnection, error)
+    connection RegisterConnection
+    mutex sync.Mutex
+    olat [2]byte
+}
+
+func NewMCP23017Driver(addr int) *MCP23017Driver {
+    driver := &MCP23017Driver {
+        name: "MCP23017",
+        address: addr,
+        ports: 2,
+    }
+
+    driver.open = func() (RegisterConnection, error) {
+        return openI2CConnection(addr)
+    }
+
+    return driver
+}
+
+func (d *MCP23017Driver) Name() string { return d.name }
+func (d *MCP23017Driver) SetName(newName string ) { d.name = newName }
+func (d *MCP23017Driver) Address() int { return d.address }
+func (d *MCP23017Driver) Connection() RegisterConnection { return d.connection }
+
+// Pins is the number of GPIO pins, MCP23017_PINS or MCP23008_PINS.
+//
+func (d *MCP23017Driver) Pins() int { return d.ports * 8 }
+
+// Initializes and opens a connection to the chip, on the default I2C
+// bus or, for an MCP23S17, its spidev device.
+//
+func (d *MCP23017Driver) Start() (err error) {
+    connection, err := d.open()
+    if err != nil {
+        return err
+    }
+
+    return d.StartWithConnection(connection)
+}
+
+// Initializes the driver over a connection that has already been
+// opened, such as a SimulatedConnection or a device on another bus.
+//
+func (d *MCP23017Driver) StartWithConnection(connection RegisterConnection) (err error) {
+    d.connection = connection
+
+    if d.ioconFixed != 0 {
+        if err = d.SetIOCON(0) ; err != nil {
+            return err
+        }
+    }
+
+    // Fill the output latch cache from the chip.
+    //
+    for port := PORT_A ; port < Port(d.ports) ; port++ {
+        if d.olat[port], err = d.readRegister(MCP23017_OLATA, port) ; err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+func (d *MCP23017Driver) Close() {
+    if d.connection != nil { d.connection.Close() }
+}
+
+// Returns the port and the bit within that port of a pin.
+//
+func (d *MCP23017Driver) pinLocation(pin int) (port Port, bit uint, err error) {
+    if pin < 0 || pin >= d.Pins() {
+        return PORT_A, 0, fmt.Errorf(" Pin %d is out of range 0-%d", pin, d.Pins() - 1)
+    }
+
+    return Port(pin / 8), uint(pin % 8), nil
+}
+
+// Returns the address of a register. base is the MCP23017 bank 0
+// port A register; the port B register follows it. The MCP23008 has
+// the same registers in the same order, but only port A, so its
+// addresses are half the MCP23017's.
+//
+func (d *MCP23017Driver) registerAddress(base byte, port Port) (byte, error) {
+    if d.connection == nil {
+        return 0, fmt.Errorf(" %s is not started", d.name)
+    }
+    if int(port) >= d.ports {
+        return 0, fmt.Errorf(" %s has no port %s", d.name, port)
+    }
+
+    if d.ports == 1 {
+        return base / 2, nil
+    }
+    return base + byte(port), nil
+}
+
+func (d *MCP23017Driver) readRegister(base byte, port Port) (byte, error) {
+    reg, err := d.registerAddress(base, port)
+    if err != nil {
+        return 0, err
+    }
+    return d.connection.ReadByteData(reg)
+}
+
+func (d *MCP23017Driver) writeRegister(base byte, port Port, val byte) error {
+    reg, err := d.registerAddress(base, port)
+    if err != nil {
+        return err
+    }
+    return d.connection.WriteByteData(reg, val)
+}
+
+// Read-modify-write of a single bit in one of a pair of registers.
+//
+func (d *MCP23017Driver) updateRegisterBit(base byte, pin int, on bool) error {
+    port, bit, err := d.pinLocation(pin)
+    if err != nil {
+        return err
+    }
+
+    d.mutex.Lock()
+    defer d.mutex.Unlock()
+
+    val, err := d.readRegister(base, port)
+    if err != nil {
+        return err
+    }
+
+    return d.writeRegister(base, port, setBit(val, bit, on))
+}
+
+// Reads the IOCON configuration register.
+//
+func (d *MCP23017Driver) IOCON() (byte, error) {
+    return d.readRegister(MCP23017_IOCON, PORT_A)
+}
+
+// Writes the IOCON configuration register. The driver addresses
+// registers in bank 0, so MCP23017_IOCON_BANK is always left clear.
+// On an MCP23S17 MCP23017_IOCON_HAEN is always left set.
+//
+func (d *MCP23017Driver) SetIOCON(val byte) error {
+    return d.writeRegister(MCP23017_IOCON, PORT_A, val &^ MCP23017_IOCON_BANK | d.ioconFixed)
+}
+
+// Sets a single pin as an output, an input, or an input with the
+// internal 100k pull-up enabled.
+//
+func (d *MCP23017Driver) PinMode(pin int, mode PinMode) error {
+    if err := d.updateRegisterBit(MCP23017_IODIRA, pin, mode != PIN_OUTPUT) ; err != nil {
+        return err
+    }
+
+    if mode == PIN_OUTPUT {
+        return nil
+    }
+
+    return d.SetPullUp(pin, mode == PIN_INPUT_PULLUP)
+}
+
+// Sets the direction of all eight pins of a port at once.
+// A 1 bit makes the pin an input, a 0 bit an output.
+//
+func (d *MCP23017Driver) SetPortDirection(port Port, inputs byte) error {
+    return d.writeRegister(MCP23017_IODIRA, port, inputs)
+}
+
+// Enables or disables the internal pull-up on an input pin.
+//
+func (d *MCP23017Driver) SetPullUp(pin int, on bool) error {
+    return d.updateRegisterBit(MCP23017_GPPUA, pin, on)
+}
+
+// Enables the internal pull-ups on a port. A 1 bit enables the pull-up.
+//
+func (d *MCP23017Driver) SetPortPullUps(port Port, pullups byte) error {
+    return d.writeRegister(MCP23017_GPPUA, port, pullups)
+}
+
+// Inverts, or not, the value read from an input pin.
+//
+func (d *MCP23017Driver) SetInputPolarity(pin int, inverted bool) error {
+    return d.updateRegisterBit(MCP23017_IPOLA, pin, inverted)
+}
+
+// Sets the input polarity of a port. A 1 bit inverts the pin.
+//
+func (d *MCP23017Driver) SetPortPolarity(port Port, inverted byte) error {
+    return d.writeRegister(MCP23017_IPOLA, port, inverted)
+}
+
+// Drives an output pin high or low.
+//
+func (d *MCP23017Driver) DigitalWrite(pin int, high bool) error {
+    port, bit, err := d.pinLocation(pin)
+    if err != nil {
+        return err
+    }
+
+    d.mutex.Lock()
+    defer d.mutex.Unlock()
+
+    return d.writeLatch(port, setBit(d.olat[port], bit, high))
+}
+
+// Reads the level on a pin, after any input polarity inversion.
+//
+func (d *MCP23017Driver) DigitalRead(pin int) (bool, error) {
+    port, bit, err := d.pinLocation(pin)
+    if err != nil {
+        return false, err
+    }
+
+    val, err := d.ReadPort(port)
+    return val & (1 << bit) != 0, err
+}
+
+// Writes all eight output latches of a port.
+//
+func (d *MCP23017Driver) WritePort(port Port, val byte) error {
+    d.mutex.Lock()
+    defer d.mutex.Unlock()
+
+    return d.writeLatch(port, val)
+}
+
+// Reads the levels on all eight pins of a port.
+//
+func (d *MCP23017Driver) ReadPort(port Port) (byte, error) {
+    return d.readRegister(MCP23017_GPIOA, port)
+}
+
+// Returns the cached output latches, port A in the low byte.
+//
+func (d *MCP23017Driver) Output() uint16 {
+    d.mutex.Lock()
+    defer d.mutex.Unlock()
+
+    return uint16(d.olat[PORT_A]) | uint16(d.olat[PORT_B]) << 8
+}
+
+// Changes only the output latches selected by mask, across both ports,
+// with port A in the low byte. A port is only written if it changes,
+// which keeps strobed signals spread over both ports in step.
+//
+func (d *MCP23017Driver) WriteMasked(mask uint16, val uint16) error {
+    if mask >> uint(d.Pins()) != 0 {
+        return fmt.Errorf(" Mask 0x%04x has pins %s doesn't have", mask, d.name)
+    }
+
+    d.mutex.Lock()
+    defer d.mutex.Unlock()
+
+    for port := PORT_A ; port < Port(d.ports) ; port++ {
+        shift := uint(port) * 8
+        portMask := byte(mask >> shift)
+        latch := d.olat[port] &^ portMask | byte(val >> shift) & portMask
+
+        if latch != d.olat[port] {
+            if err := d.writeLatch(port, latch) ; err != nil {
+                return err
+            }
+        }
+    }
+
+    return nil
+}
+
+// Writes an output latch and updates the cache. Call with the mutex held.
+//
+func (d *MCP23017Driver) writeLatch(port Port, val byte) error {
+    if err := d.writeRegister(MCP23017_OLATA, port, val) ; err != nil {
+        return err
+    }
+
+    d.olat[port] = val
+    return nil
+}
+
+// A simulated MCP23017, for running MCP23017Driver without hardware.
+//
+// Registers start at their power-on values, with every pin an input.
+// Reading GPIOA or GPIOB returns the output latch on output pins and
+// the level set by SetInputs on input pins, inverted by IPOL.
+//
+// Changing inputs raises interrupts as GPINTEN, INTCON and DEFVAL ask,
+// setting INTF and latching INTCAP. Reading GPIO or INTCAP clears the
+// interrupt on that port.
+//
+type SimulatedMCP23017 struct {
+    *SimulatedConnection
+    inputs uint16
+}
+
+func NewSimulatedMCP23017() *SimulatedMCP23017 {
+    sim := &SimulatedMCP23017 {
+        SimulatedConnection: NewSimulatedConnection(),
+    }
+
+    sim.Registers[MCP23017_IODIRA] = 0xFF
+    sim.Registers[MCP23017_IODIRB] = 0xFF
+    sim.OnRead = sim.onRead
+    sim.OnWrite = sim.onWrite
+
+    return sim
+}
+
+// Sets the level on every pin, as if driven from outside the chip.
+// Pin 0 (GPA0) is bit 0. Only pins configured as inputs are affected.
+//
+func (s *SimulatedMCP23017) SetInputs(levels uint16) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    before := [2]byte{ s.pins(PORT_A), s.pins(PORT_B) }
+    s.inputs = levels
+
+    for port := PORT_A ; port <= PORT_B ; port++ {
+        after := s.pins(port)
+        enabled := s.Registers[MCP23017_GPINTENA + byte(port)]
+        compare := s.Registers[MCP23017_INTCONA + byte(port)]
+        defval := s.Registers[MCP23017_DEFVALA + byte(port)]
+
+        flags := enabled & (^compare & (before[port] ^ after) | compare & (after ^ defval))
+        if flags == 0 {
+            continue
+        }
+
+        if s.Registers[MCP23017_INTFA + byte(port)] == 0 {
+            s.Registers[MCP23017_INTCAPA + byte(port)] = after
+        }
+        s.Registers[MCP23017_INTFA + byte(port)] |= flags
+    }
+}
+
+// Whether the mirrored INTA/INTB output is asserted, in the form
+// WatchOptions.Interrupt expects.
+//
+func (s *SimulatedMCP23017) InterruptLine() (bool, error) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    return s.Registers[MCP23017_INTFA] | s.Registers[MCP23017_INTFB] != 0, nil
+}
+
+func (s *SimulatedMCP23017) pins(port Port) byte {
+    iodir := s.Registers[MCP23017_IODIRA + byte(port)]
+    ipol := s.Registers[MCP23017_IPOLA + byte(port)]
+    olat := s.Registers[MCP23017_OLATA + byte(port)]
+    external := byte(s.inputs >> (uint(port) * 8))
+
+    return olat &^ iodir | (external ^ ipol) & iodir
+}
+
+func (s *SimulatedMCP23017) onRead(reg uint8) (uint8, bool) {
+    switch reg {
+    case MCP23017_GPIOA, MCP23017_GPIOB:
+        port := Port(reg - MCP23017_GPIOA)
+        s.Registers[MCP23017_INTFA + byte(port)] = 0
+        return s.pins(port), true
+    case MCP23017_INTCAPA, MCP23017_INTCAPB:
+        s.Registers[MCP23017_INTFA + reg - MCP23017_INTCAPA] = 0
+        return s.Registers[reg], true
+    case MCP23017_IOCON + 1:
+        // IOCON appears at both addresses.
+        //
+        return s.Registers[MCP23017_IOCON], true
+    }
+    return 0, false
+}
+
+func (s *SimulatedMCP23017) onWrite(reg uint8, val uint8) {
+    switch reg {
+    case MCP23017_GPIOA, MCP23017_GPIOB:
+        // Writing a port writes its output latch.
+        //
+        s.Registers[reg + MCP23017_OLATA - MCP23017_GPIOA] = val
+    case MCP23017_IOCON + 1:
+        s.Registers[MCP23017_IOCON] = val
+    }
+}
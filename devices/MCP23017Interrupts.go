@@ -0,0 +1,312 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "fmt"
+    "sync"
+    "time"
+)
+
+// When an input pin raises an interrupt.
+//
+// INTERRUPT_ON_CHANGE compares each pin against its previous value.
+// The other two compare against DEFVAL, interrupting for as long as
+// the pin is at the given level.
+//
+type InterruptMode int
+
+const (
+    INTERRUPT_ON_CHANGE InterruptMode = iota
+    INTERRUPT_ON_LOW
+    INTERRUPT_ON_HIGH
+)
+
+// Enables interrupt-on-change for an input pin.
+//
+func (d *MCP23017Driver) EnableInterrupt(pin int, mode InterruptMode) error {
+    // DEFVAL holds the level that does not interrupt.
+    //
+    if err := d.updateRegisterBit(MCP23017_DEFVALA, pin, mode == INTERRUPT_ON_LOW) ; err != nil {
+        return err
+    }
+
+    if err := d.updateRegisterBit(MCP23017_INTCONA, pin, mode != INTERRUPT_ON_CHANGE) ; err != nil {
+        return err
+    }
+
+    return d.updateRegisterBit(MCP23017_GPINTENA, pin, true)
+}
+
+// Disables interrupt-on-change for a pin.
+//
+func (d *MCP23017Driver) DisableInterrupt(pin int) error {
+    return d.updateRegisterBit(MCP23017_GPINTENA, pin, false)
+}
+
+// Reads a register pair as one 16-bit value, port A in the low byte.
+// An MCP23008 only has the port A register.
+//
+func (d *MCP23017Driver) readRegisterPair(base byte) (uint16, error) {
+    low, err := d.readRegister(base, PORT_A)
+    if err != nil || d.ports == 1 {
+        return uint16(low), err
+    }
+
+    high, err := d.readRegister(base, PORT_B)
+    return uint16(low) | uint16(high) << 8, err
+}
+
+// Reads the levels on all sixteen pins, GPA0 in bit 0.
+//
+func (d *MCP23017Driver) ReadPins() (uint16, error) {
+    return d.readRegisterPair(MCP23017_GPIOA)
+}
+
+// Reads INTF, which has a bit set for each pin that caused an
+// interrupt, GPA0 in bit 0.
+//
+func (d *MCP23017Driver) InterruptFlags() (uint16, error) {
+    return d.readRegisterPair(MCP23017_INTFA)
+}
+
+// Reads INTCAP, the pin levels captured when the interrupt occurred.
+// Reading INTCAP clears the interrupt.
+//
+func (d *MCP23017Driver) InterruptCapture() (uint16, error) {
+    return d.readRegisterPair(MCP23017_INTCAPA)
+}
+
+// A debounced change of level on an input pin.
+//
+type PinEvent struct {
+    Pin int
+    High bool
+    Time time.Time
+}
+
+func (e PinEvent) String() string {
+    edge := "falling"
+    if e.High { edge = "rising" }
+
+    return fmt.Sprintf("%s GP%s%d %s", e.Time.Format("15:04:05.000"), Port(e.Pin / 8), e.Pin % 8, edge)
+}
+
+// How Watch looks for changes on input pins.
+//
+// Pins has a bit set for each pin to watch, GPA0 in bit 0. The pins
+// must already be inputs. A new level must hold for Debounce before it
+// is reported. Watch checks for changes every PollInterval.
+//
+// Interrupt, if set, reports whether the MCP23017's interrupt output
+// is asserted, typically by reading the Raspberry Pi GPIO it is wired
+// to. The MCP23017 is then only read when there is something to read.
+// Without it INTF is polled over I2C, which is still a single read no
+// matter how many pins are watched.
+//
+type WatchOptions struct {
+    Pins uint16
+    Debounce time.Duration
+    PollInterval time.Duration
+    Interrupt func() (bool, error)
+}
+
+// Default timing for Watch.
+//
+const (
+    DEFAULT_DEBOUNCE = 20 * time.Millisecond
+    DEFAULT_POLL_INTERVAL = 5 * time.Millisecond
+)
+
+// Enables interrupt-on-change on the watched pins and publishes a
+// PinEvent on the returned channel for each debounced edge. The
+// interrupt outputs are mirrored, so either INTA or INTB can be wired
+// for the Interrupt option.
+//
+// Call stop to stop watching. The pins' interrupts are disabled and
+// the channel is closed.
+//
+func (d *MCP23017Driver) Watch(options WatchOptions) (events <-chan PinEvent, stop func(), err error) {
+    if options.Pins == 0 {
+        return nil, nil, fmt.Errorf(" No pins to watch")
+    }
+    if options.Pins >> uint(d.Pins()) != 0 {
+        return nil, nil, fmt.Errorf(" %s doesn't have all the pins to watch", d.name)
+    }
+    if options.Debounce == 0 { options.Debounce = DEFAULT_DEBOUNCE }
+    if options.PollInterval == 0 { options.PollInterval = DEFAULT_POLL_INTERVAL }
+
+    iocon, err := d.IOCON()
+    if err != nil {
+        return nil, nil, err
+    }
+    if err = d.SetIOCON(iocon | MCP23017_IOCON_MIRROR) ; err != nil {
+        return nil, nil, err
+    }
+
+    for pin := 0 ; pin < d.Pins() ; pin++ {
+        if options.Pins & (1 << uint(pin)) != 0 {
+            if err = d.EnableInterrupt(pin, INTERRUPT_ON_CHANGE) ; err != nil {
+                return nil, nil, err
+            }
+        }
+    }
+
+    // Reading the pins also clears any interrupt left over from before.
+    //
+    levels, err := d.ReadPins()
+    if err != nil {
+        return nil, nil, err
+    }
+
+    channel := make(chan PinEvent, 16)
+    done := make(chan struct{})
+    watcher := &pinWatcher {
+        driver: d,
+        options: options,
+        reported: levels,
+        events: channel,
+        done: done,
+    }
+
+    go watcher.run()
+
+    var once sync.Once
+    stop = func() {
+        once.Do(func() { close(done) })
+    }
+
+    return channel, stop, nil
+}
+
+type pinWatcher struct {
+    driver *MCP23017Driver
+    options WatchOptions
+    reported uint16
+    pending uint16
+    pendingLevels uint16
+    pendingSince [MCP23017_PINS]time.Time
+    events chan PinEvent
+    done chan struct{}
+}
+
+func (w *pinWatcher) run() {
+    ticker := time.NewTicker(w.options.PollInterval)
+    defer ticker.Stop()
+    defer close(w.events)
+    defer w.disable()
+
+    for {
+        select {
+        case <-w.done:
+            return
+        case <-ticker.C:
+            w.check()
+        }
+    }
+}
+
+func (w *pinWatcher) disable() {
+    for pin := 0 ; pin < MCP23017_PINS ; pin++ {
+        if w.options.Pins & (1 << uint(pin)) != 0 {
+            w.driver.DisableInterrupt(pin)
+        }
+    }
+}
+
+// Looks for new edges, then reports any that have held long enough.
+// Errors reading the chip are not fatal; the next check tries again.
+//
+func (w *pinWatcher) check() {
+    now := time.Now()
+    interrupted := true
+
+    if w.options.Interrupt != nil {
+        if asserted, err := w.options.Interrupt() ; err == nil {
+            interrupted = asserted
+        }
+    }
+
+    if interrupted {
+        flags, err := w.driver.InterruptFlags()
+        if err != nil {
+            return
+        }
+
+        if flags & w.options.Pins != 0 {
+            // INTCAP holds the levels that caused the interrupt, so
+            // an edge is seen even if the pin has already bounced back.
+            //
+            captured, err := w.driver.InterruptCapture()
+            if err != nil {
+                return
+            }
+            w.track(flags & w.options.Pins, captured, now)
+        }
+    }
+
+    if w.pending == 0 {
+        return
+    }
+
+    levels, err := w.driver.ReadPins()
+    if err != nil {
+        return
+    }
+
+    w.track(w.pending, levels, now)
+
+    for pin := 0 ; pin < MCP23017_PINS ; pin++ {
+        bit := uint16(1) << uint(pin)
+        if w.pending & bit == 0 || now.Sub(w.pendingSince[pin]) < w.options.Debounce {
+            continue
+        }
+
+        w.pending &^= bit
+        if (w.reported ^ w.pendingLevels) & bit == 0 {
+            continue
+        }
+
+        w.reported ^= bit
+        event := PinEvent { Pin: pin, High: w.reported & bit != 0, Time: now }
+
+        select {
+        case w.events <- event:
+        case <-w.done:
+            return
+        }
+    }
+}
+
+// Notes the level of each pin in pins, restarting the debounce time of
+// any pin whose level differs from the one already pending.
+//
+func (w *pinWatcher) track(pins uint16, levels uint16, now time.Time) {
+    for pin := 0 ; pin < MCP23017_PINS ; pin++ {
+        bit := uint16(1) << uint(pin)
+        if pins & bit == 0 {
+            continue
+        }
+
+        level := levels & bit
+        if w.pending & bit == 0 || w.pendingLevels & bit != level {
+            w.pending |= bit
+            w.pendingLevels = w.pendingLevels &^ bit | level
+            w.pendingSince[pin] = now
+        }
+    }
+}
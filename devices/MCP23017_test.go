@@ -0,0 +1,218 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "testing"
+)
+
+func startSimulatedMCP23017(t *testing.T) (*MCP23017Driver, *SimulatedMCP23017) {
+    sim := NewSimulatedMCP23017()
+    driver := NewMCP23017Driver(MCP23017_DEFAULT_ADDRESS)
+
+    if err := driver.StartWithConnection(sim) ; err != nil {
+        t.Fatal(err)
+    }
+    return driver, sim
+}
+
+// Records the registers written, in order.
+//
+func recordWrites(sim *SimulatedConnection) *[]uint8 {
+    var writes []uint8
+    onWrite := sim.OnWrite
+
+    sim.OnWrite = func(reg uint8, val uint8) {
+        writes = append(writes, reg)
+        if onWrite != nil {
+            onWrite(reg, val)
+        }
+    }
+    return &writes
+}
+
+func TestMCP23017LatchCache(t *testing.T) {
+    sim := NewSimulatedMCP23017()
+    sim.Registers[MCP23017_OLATA] = 0x81
+    sim.Registers[MCP23017_OLATB] = 0x42
+
+    driver := NewMCP23017Driver(MCP23017_DEFAULT_ADDRESS)
+    if err := driver.StartWithConnection(sim) ; err != nil {
+        t.Fatal(err)
+    }
+    if out := driver.Output() ; out != 0x4281 {
+        t.Fatalf("the cache was loaded as 0x%04x, not 0x4281", out)
+    }
+
+    driver.DigitalWrite(3, true)
+    driver.DigitalWrite(8, true)
+    driver.DigitalWrite(14, false)
+    if a, b := sim.Register(MCP23017_OLATA), sim.Register(MCP23017_OLATB) ; a != 0x89 || b != 0x03 {
+        t.Errorf("OLAT is %02x %02x, not 89 03", a, b)
+    }
+
+    // Setting a pin writes the latch from the cache, without reading
+    // it back from the chip first.
+    //
+    writes := recordWrites(sim.SimulatedConnection)
+    sim.SetRegister(MCP23017_OLATA, 0x00)
+    driver.DigitalWrite(4, true)
+    if a := sim.Register(MCP23017_OLATA) ; a != 0x99 {
+        t.Errorf("OLATA is %02x, not 99 from the cache", a)
+    }
+    if len(*writes) != 1 || (*writes)[0] != MCP23017_OLATA {
+        t.Errorf("setting a pin wrote registers % x, not just OLATA", *writes)
+    }
+
+    // WriteMasked only writes the ports that change.
+    //
+    *writes = nil
+    if err := driver.WriteMasked(0xFF00, 0x0300) ; err != nil {
+        t.Fatal(err)
+    }
+    if len(*writes) != 0 {
+        t.Errorf("an unchanged port was written: % x", *writes)
+    }
+    if err := driver.WriteMasked(0x0F0F, 0xA005) ; err != nil {
+        t.Fatal(err)
+    }
+    if out := driver.Output() ; out != 0x0095 {
+        t.Errorf("output is 0x%04x, not 0x0095", out)
+    }
+    if len(*writes) != 2 {
+        t.Errorf("changing both ports wrote % x", *writes)
+    }
+}
+
+func TestMCP23017PinMode(t *testing.T) {
+    driver, sim := startSimulatedMCP23017(t)
+
+    for _, step := range []struct {
+        pin int
+        mode PinMode
+    } {
+        { 0, PIN_OUTPUT },
+        { 7, PIN_INPUT_PULLUP },
+        { 9, PIN_OUTPUT },
+        { 15, PIN_INPUT_PULLUP },
+        { 7, PIN_INPUT },
+    } {
+        if err := driver.PinMode(step.pin, step.mode) ; err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    for _, reg := range []struct {
+        name string
+        reg byte
+        want byte
+    } {
+        { "IODIRA", MCP23017_IODIRA, 0xFE },
+        { "IODIRB", MCP23017_IODIRB, 0xFD },
+        { "GPPUA", MCP23017_GPPUA, 0x00 },
+        { "GPPUB", MCP23017_GPPUB, 0x80 },
+    } {
+        if got := sim.Register(reg.reg) ; got != reg.want {
+            t.Errorf("%s is %02x, not %02x", reg.name, got, reg.want)
+        }
+    }
+
+    if err := driver.PinMode(16, PIN_OUTPUT) ; err == nil {
+        t.Error("pin 16 was accepted")
+    }
+    if err := driver.DigitalWrite(-1, true) ; err == nil {
+        t.Error("pin -1 was accepted")
+    }
+}
+
+func TestMCP23017DigitalReadWrite(t *testing.T) {
+    driver, sim := startSimulatedMCP23017(t)
+
+    driver.PinMode(0, PIN_OUTPUT)
+    driver.PinMode(12, PIN_OUTPUT)
+    driver.DigitalWrite(0, true)
+    driver.DigitalWrite(12, true)
+    sim.SetInputs(1 << 2 | 1 << 10)
+
+    for pin, want := range map[int]bool {
+        0: true, 1: false, 2: true,
+        9: false, 10: true, 12: true,
+    } {
+        got, err := driver.DigitalRead(pin)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got != want {
+            t.Errorf("pin %d reads %v, not %v", pin, got, want)
+        }
+    }
+
+    if b, _ := driver.ReadPort(PORT_B) ; b != 0x14 {
+        t.Errorf("port B reads %02x, not 14", b)
+    }
+
+    driver.SetInputPolarity(10, true)
+    if high, _ := driver.DigitalRead(10) ; high {
+        t.Error("inverted pin 10 reads high")
+    }
+    if sim.Register(MCP23017_IPOLB) != 0x04 {
+        t.Errorf("IPOLB is %02x, not 04", sim.Register(MCP23017_IPOLB))
+    }
+
+    driver.WritePort(PORT_B, 0x00)
+    if high, _ := driver.DigitalRead(12) ; high {
+        t.Error("pin 12 reads high after port B was cleared")
+    }
+}
+
+func TestMCP23017IOCON(t *testing.T) {
+    driver, sim := startSimulatedMCP23017(t)
+
+    // BANK would move every register, so the driver never sets it.
+    //
+    if err := driver.SetIOCON(MCP23017_IOCON_BANK | MCP23017_IOCON_MIRROR | MCP23017_IOCON_ODR) ; err != nil {
+        t.Fatal(err)
+    }
+    want := MCP23017_IOCON_MIRROR | MCP23017_IOCON_ODR
+    if got := sim.Register(MCP23017_IOCON) ; got != want {
+        t.Errorf("IOCON is %02x, not %02x", got, want)
+    }
+    if got, err := driver.IOCON() ; err != nil || got != want {
+        t.Errorf("IOCON reads %02x, %v, not %02x", got, err, want)
+    }
+
+    // An MCP23017 has no fixed bits, so starting leaves IOCON alone.
+    //
+    restarted := NewMCP23017Driver(MCP23017_DEFAULT_ADDRESS)
+    if err := restarted.StartWithConnection(sim) ; err != nil {
+        t.Fatal(err)
+    }
+    if got := sim.Register(MCP23017_IOCON) ; got != want {
+        t.Errorf("IOCON is %02x after restarting, not %02x", got, want)
+    }
+}
+
+func TestMCP23017NotStarted(t *testing.T) {
+    driver := NewMCP23017Driver(MCP23017_DEFAULT_ADDRESS)
+
+    if err := driver.DigitalWrite(0, true) ; err == nil {
+        t.Error("wrote a pin before starting")
+    }
+    if _, err := driver.ReadPort(PORT_A) ; err == nil {
+        t.Error("read a port before starting")
+    }
+}
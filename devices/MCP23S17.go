@@ -0,0 +1,187 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "fmt"
+    "sync"
+
+    "gobot.io/x/gobot/drivers/spi"
+)
+
+// The MCP23S17 is an MCP23017 on SPI. Every transfer starts with an
+// opcode, 0100 A2 A1 A0 R/W, followed by the register address and the
+// data. A0-A2 are the chip's hardware address, which lets up to eight
+// MCP23S17s share one chip select once IOCON.HAEN is set.
+//
+const (
+    MCP23S17_OPCODE_WRITE byte = 0x40
+    MCP23S17_OPCODE_READ byte = 0x41
+)
+
+// MCP23S17_MAX_SPEED is the fastest SPI clock the MCP23S17 takes, 10MHz.
+//
+const MCP23S17_MAX_SPEED int64 = 10000000
+
+// A RegisterConnection to one MCP23S17 over an SPI connection, which
+// may be shared with other MCP23S17s on the same chip select.
+//
+type MCP23S17Connection struct {
+    spi spi.Connection
+    address byte
+}
+
+func NewMCP23S17Connection(connection spi.Connection, hardwareAddress int) *MCP23S17Connection {
+    return &MCP23S17Connection {
+        spi: connection,
+        address: byte(hardwareAddress & 0x07) << 1,
+    }
+}
+
+func (c *MCP23S17Connection) ReadByteData(reg uint8) (uint8, error) {
+    read := make([]byte, 3)
+    if err := c.spi.Tx([]byte{ MCP23S17_OPCODE_READ | c.address, reg, 0 }, read) ; err != nil {
+        return 0, err
+    }
+    return read[2], nil
+}
+
+func (c *MCP23S17Connection) WriteByteData(reg uint8, val uint8) error {
+    return c.spi.Tx([]byte{ MCP23S17_OPCODE_WRITE | c.address, reg, val }, nil)
+}
+
+func (c *MCP23S17Connection) Close() error {
+    return c.spi.Close()
+}
+
+// A driver for an MCP23S17 on /dev/spidev<bus>.<chipSelect>, strapped
+// to hardwareAddress 0-7. It has the same registers and pins as the
+// MCP23017. Address returns the hardware address.
+//
+// The driver sets IOCON.HAEN when it starts, so that only this chip
+// answers to its hardware address.
+//
+func NewMCP23S17Driver(bus int, chipSelect int, hardwareAddress int) *MCP23017Driver {
+    driver := NewMCP23017Driver(hardwareAddress)
+    driver.name = "MCP23S17"
+    driver.ioconFixed = MCP23017_IOCON_HAEN
+
+    driver.open = func() (RegisterConnection, error) {
+        if hardwareAddress < 0 || hardwareAddress > 7 {
+            return nil, fmt.Errorf(" Hardware address %d is out of range 0-7", hardwareAddress)
+        }
+
+        connection, err := spi.GetSpiConnection(bus, chipSelect, 0, 8, MCP23S17_MAX_SPEED)
+        if err != nil {
+            return nil, err
+        }
+
+        fmt.Printf(" Using MCP23S17 %d on /dev/spidev%d.%d\n", hardwareAddress, bus, chipSelect)
+        return NewMCP23S17Connection(connection, hardwareAddress), nil
+    }
+
+    return driver
+}
+
+// A simulated SPI device with MCP23S17s on it, for running the
+// MCP23S17 driver without hardware. It implements spi.Connection, so
+// pass it to NewMCP23S17Connection, then that to StartWithConnection.
+//
+// Each chip is a SimulatedMCP23017 at a hardware address. Until a
+// chip's IOCON.HAEN is set it ignores the address in the opcode, as
+// the real chip does, so a write before then reaches every chip.
+//
+// Transfers records every transfer written, for checking the bytes
+// on the wire.
+//
+type SimulatedSPIDevice struct {
+    Chips map[int]*SimulatedMCP23017
+    Transfers [][]byte
+    mutex sync.Mutex
+    closed bool
+}
+
+func NewSimulatedSPIDevice(hardwareAddresses ...int) *SimulatedSPIDevice {
+    device := &SimulatedSPIDevice {
+        Chips: map[int]*SimulatedMCP23017{},
+    }
+
+    for _, address := range hardwareAddresses {
+        device.Chips[address] = NewSimulatedMCP23017()
+    }
+
+    return device
+}
+
+func (s *SimulatedSPIDevice) Closed() bool {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    return s.closed
+}
+
+func (s *SimulatedSPIDevice) Close() error {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    s.closed = true
+    return nil
+}
+
+// Decodes one MCP23S17 transfer, passing it to every chip that
+// answers to its opcode.
+//
+func (s *SimulatedSPIDevice) Tx(w, r []byte) error {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    if s.closed {
+        return fmt.Errorf(" SPI device is closed")
+    }
+    s.Transfers = append(s.Transfers, append([]byte{}, w...))
+
+    if len(w) < 3 || w[0] &^ 0x0F != MCP23S17_OPCODE_WRITE {
+        return fmt.Errorf(" Not an MCP23S17 transfer: % x", w)
+    }
+    if r != nil && len(r) < len(w) {
+        return fmt.Errorf(" Read buffer is shorter than the transfer")
+    }
+
+    address := int(w[0] >> 1 & 0x07)
+    reading := w[0] & 0x01 != 0
+
+    for chipAddress, chip := range s.Chips {
+        haen := chip.Register(MCP23017_IOCON) & MCP23017_IOCON_HAEN != 0
+        if haen && chipAddress != address {
+            continue
+        }
+
+        if reading {
+            val, err := chip.ReadByteData(w[1])
+            if err != nil {
+                return err
+            }
+            if r != nil {
+                r[2] = val
+            }
+        } else if err := chip.WriteByteData(w[1], w[2]) ; err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,96 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "bytes"
+    "testing"
+)
+
+func TestMCP23S17Framing(t *testing.T) {
+    device := NewSimulatedSPIDevice(0, 3)
+    driver := NewMCP23S17Driver(0, 0, 3)
+
+    if err := driver.StartWithConnection(NewMCP23S17Connection(device, 3)) ; err != nil {
+        t.Fatal(err)
+    }
+
+    // Starting sets HAEN before anything else, and as no chip has it
+    // yet the write reaches both.
+    //
+    if len(device.Transfers) == 0 || !bytes.Equal(device.Transfers[0], []byte{ 0x46, MCP23017_IOCON, MCP23017_IOCON_HAEN }) {
+        t.Fatalf("the transfers began % x, not 46 0a 08", device.Transfers)
+    }
+    for address, chip := range device.Chips {
+        if chip.Register(MCP23017_IOCON) != MCP23017_IOCON_HAEN {
+            t.Errorf("chip %d IOCON is %02x, not HAEN", address, chip.Register(MCP23017_IOCON))
+        }
+    }
+
+    for _, step := range []struct {
+        name string
+        do func() error
+        want []byte
+    } {
+        { "PinMode", func() error { return driver.PinMode(8, PIN_OUTPUT) },
+            []byte{ 0x47, MCP23017_IODIRB, 0x00 } },
+        { "DigitalWrite", func() error { return driver.DigitalWrite(8, true) },
+            []byte{ 0x46, MCP23017_OLATB, 0x01 } },
+        { "ReadPort", func() error { _, err := driver.ReadPort(PORT_A) ; return err },
+            []byte{ 0x47, MCP23017_GPIOA, 0x00 } },
+    } {
+        before := len(device.Transfers)
+        if err := step.do() ; err != nil {
+            t.Fatal(err)
+        }
+        if len(device.Transfers) == before || !bytes.Equal(device.Transfers[before], step.want) {
+            t.Errorf("%s sent % x, not % x", step.name, device.Transfers[before:], step.want)
+        }
+    }
+
+    // With HAEN set only chip 3 answers.
+    //
+    if device.Chips[3].Register(MCP23017_OLATB) != 0x01 || device.Chips[0].Register(MCP23017_OLATB) != 0x00 {
+        t.Errorf("OLATB is %02x on chip 3 and %02x on chip 0, not 01 and 00",
+            device.Chips[3].Register(MCP23017_OLATB), device.Chips[0].Register(MCP23017_OLATB))
+    }
+    device.Chips[3].SetInputs(0x05)
+    device.Chips[0].SetInputs(0xA0)
+    if a, err := driver.ReadPort(PORT_A) ; err != nil || a != 0x05 {
+        t.Errorf("port A reads %02x, %v, not 05 from chip 3", a, err)
+    }
+
+    // HAEN stays set whatever IOCON is set to.
+    //
+    if err := driver.SetIOCON(MCP23017_IOCON_MIRROR) ; err != nil {
+        t.Fatal(err)
+    }
+    if iocon := device.Chips[3].Register(MCP23017_IOCON) ; iocon != MCP23017_IOCON_MIRROR | MCP23017_IOCON_HAEN {
+        t.Errorf("IOCON is %02x, not MIRROR | HAEN", iocon)
+    }
+
+    driver.Close()
+    if !device.Closed() {
+        t.Error("closing the driver left the SPI device open")
+    }
+}
+
+func TestMCP23S17HardwareAddress(t *testing.T) {
+    if err := NewMCP23S17Driver(0, 0, 8).Start() ; err == nil {
+        t.Error("hardware address 8 was accepted")
+    }
+}
@@ -0,0 +1,360 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// How long each step of a scroll is shown, and how long a message that
+// isn't scrolled is held when it gives no minimum time.
+//
+const (
+    SCHEDULER_SCROLL_DELAY = 400 * time.Millisecond
+    SCHEDULER_HOLD_TIME = 2 * time.Second
+)
+
+// REPEAT_FOREVER keeps a message showing until it expires or is removed.
+//
+const REPEAT_FOREVER int = -1
+
+// A Message for a MessageScheduler.
+//
+// Higher Priority messages are shown first, and interrupt lower ones.
+// MinimumTime is how long the message is guaranteed the display once
+// it is shown, even against higher priorities; a message that isn't
+// scrolled is held for MinimumTime, or SCHEDULER_HOLD_TIME if it is
+// zero. The message is shown Repeat more times after the first, or
+// until it is removed with REPEAT_FOREVER. A message is dropped at
+// Expires, if it is set, whether it has been shown or not.
+//
+type Message struct {
+    Text string
+    Scroll bool
+    Priority int
+    MinimumTime time.Duration
+    Repeat int
+    Expires time.Time
+}
+
+// A message waiting or showing, and how far it has got, so that it
+// picks up where it left off after being interrupted. passed is set at
+// the end of each pass, for next to give another message a turn.
+//
+type scheduledMessage struct {
+    Message
+    id int
+    shown int
+    offset int
+    started time.Time
+    passed bool
+}
+
+func (m *scheduledMessage) expired(now time.Time) bool {
+    return !m.Expires.IsZero() && !now.Before(m.Expires)
+}
+
+func (m *scheduledMessage) finished() bool {
+    return m.Repeat != REPEAT_FOREVER && m.shown > m.Repeat
+}
+
+// MessageScheduler shares one TextDisplay between several sources of
+// messages. The highest priority message is shown, and messages of
+// equal priority take turns, a pass each. When a higher priority
+// message arrives, the one showing is interrupted as soon as its
+// MinimumTime is up, even in the middle of a scroll, and resumes where
+// it was once the display is free again.
+//
+// Scrolling is done a character at a time with Write, rather than with
+// the display's own Scroll, so that it can be interrupted.
+//
+type MessageScheduler struct {
+    name string
+    display TextDisplay
+    scrollDelay time.Duration
+
+    mutex sync.Mutex
+    messages []*scheduledMessage
+    current *scheduledMessage
+    nextID int
+    wake chan struct{}
+    stop chan struct{}
+    done chan struct{}
+}
+
+func NewMessageScheduler(display TextDisplay) *MessageScheduler {
+    return &MessageScheduler {
+        name: "MessageScheduler",
+        display: display,
+        scrollDelay: SCHEDULER_SCROLL_DELAY,
+        wake: make(chan struct{}, 1),
+    }
+}
+
+func (s *MessageScheduler) Name() string { return s.name }
+func (s *MessageScheduler) SetName(newName string) { s.name = newName }
+func (s *MessageScheduler) Display() TextDisplay { return s.display }
+func (s *MessageScheduler) SetScrollDelay(delay time.Duration) { s.scrollDelay = delay }
+
+// Queues a message and returns an id for Remove.
+//
+func (s *MessageScheduler) Add(message Message) int {
+    s.mutex.Lock()
+    s.nextID++
+    id := s.nextID
+    s.messages = append(s.messages, &scheduledMessage{Message: message, id: id})
+    s.mutex.Unlock()
+
+    s.signal()
+    return id
+}
+
+// Removes a message, whether it is waiting or showing, returning false
+// if it had already gone.
+//
+func (s *MessageScheduler) Remove(id int) bool {
+    s.mutex.Lock()
+    removed := false
+    for i, m := range s.messages {
+        if m.id == id {
+            s.messages = append(s.messages[:i], s.messages[i + 1:]...)
+            removed = true
+            break
+        }
+    }
+    s.mutex.Unlock()
+
+    if removed {
+        s.signal()
+    }
+    return removed
+}
+
+// Returns the messages still to be shown, including the one showing,
+// highest priority first.
+//
+func (s *MessageScheduler) Messages() []Message {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    messages := make([]Message, len(s.messages))
+    for i, m := range s.messages {
+        messages[i] = m.Message
+    }
+    sort.SliceStable(messages, func(i, j int) bool { return messages[i].Priority > messages[j].Priority })
+    return messages
+}
+
+// Returns the message showing, if there is one.
+//
+func (s *MessageScheduler) Current() (Message, bool) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    if s.current == nil {
+        return Message{}, false
+    }
+    return s.current.Message, true
+}
+
+func (s *MessageScheduler) signal() {
+    select {
+    case s.wake <- struct{}{}:
+    default:
+    }
+}
+
+// Starts showing messages in the background.
+//
+func (s *MessageScheduler) Start() {
+    s.stop = make(chan struct{})
+    s.done = make(chan struct{})
+    go s.run()
+}
+
+// Stops showing messages and clears the display. Messages still waiting
+// are kept, for another Start.
+//
+func (s *MessageScheduler) Stop() {
+    if s.stop == nil {
+        return
+    }
+    close(s.stop)
+    <-s.done
+    s.stop = nil
+    s.display.Clear()
+}
+
+// Picks the message to show next. The one showing keeps the display
+// until its minimum time is up; after that the highest priority wins,
+// with the one showing kept among equals until it finishes a pass.
+//
+func (s *MessageScheduler) next(now time.Time) *scheduledMessage {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    // After a pass, the message showing goes to the back of the queue,
+    // so that the oldest waiting message of its priority is shown next,
+    // and one that repeats forever doesn't starve the others.
+    //
+    if m := s.current ; m != nil && m.passed {
+        if !now.Before(m.started.Add(m.MinimumTime)) {
+            s.requeue(m)
+        }
+        m.passed = false
+    }
+
+    var best *scheduledMessage
+    waiting := s.messages[:0]
+    for _, m := range s.messages {
+        if m.expired(now) || m.finished() {
+            continue
+        }
+        waiting = append(waiting, m)
+        if best == nil || m.Priority > best.Priority {
+            best = m
+        }
+    }
+    s.messages = waiting
+
+    current := s.current
+    if current != nil && (current.expired(now) || current.finished() || !s.holds(current)) {
+        current = nil
+    }
+    if current != nil && (best == current || best.Priority < current.Priority || now.Before(current.started.Add(current.MinimumTime))) {
+        return current
+    }
+
+    if best != nil {
+        best.started = now
+    }
+    s.current = best
+    return best
+}
+
+// Moves a message that is still queued to the back of the queue.
+//
+func (s *MessageScheduler) requeue(message *scheduledMessage) {
+    for i, m := range s.messages {
+        if m == message {
+            s.messages = append(append(s.messages[:i], s.messages[i + 1:]...), m)
+            return
+        }
+    }
+}
+
+// Whether a message is still queued, rather than removed.
+//
+func (s *MessageScheduler) holds(message *scheduledMessage) bool {
+    for _, m := range s.messages {
+        if m == message {
+            return true
+        }
+    }
+    return false
+}
+
+// Shows one step of a message, a whole message that isn't scrolled or
+// one window of a scroll, and returns how long to leave it.
+//
+func (s *MessageScheduler) show(m *scheduledMessage, now time.Time) time.Duration {
+    width := s.display.Width()
+
+    if !m.Scroll {
+        hold := m.MinimumTime
+        if hold == 0 {
+            hold = SCHEDULER_HOLD_TIME
+        }
+        if m.offset == 0 {
+            s.display.Write(m.Text)
+            s.mutex.Lock()
+            m.offset = 1
+            m.started = now
+            s.mutex.Unlock()
+        }
+
+        remaining := m.started.Add(hold).Sub(now)
+        if remaining <= 0 {
+            s.mutex.Lock()
+            m.shown++
+            m.offset = 0
+            m.passed = true
+            s.mutex.Unlock()
+            return 0
+        }
+        return remaining
+    }
+
+    padding := strings.Repeat(" ", width)
+    text := []rune(padding + m.Text + padding)
+    s.display.Write(string(text[m.offset:m.offset + width]))
+
+    s.mutex.Lock()
+    if m.offset++ ; m.offset + width > len(text) {
+        m.shown++
+        m.offset = 0
+        m.passed = true
+    }
+    s.mutex.Unlock()
+    return s.scrollDelay
+}
+
+func (s *MessageScheduler) run() {
+    defer close(s.done)
+
+    var last *scheduledMessage
+    var due time.Time
+    for {
+        now := time.Now()
+        m := s.next(now)
+
+        // A message that was interrupted is written again when it
+        // resumes, and the display is cleared when there is nothing.
+        //
+        if m != last {
+            if m != nil && !m.Scroll {
+                m.offset = 0
+            }
+            if m == nil {
+                s.display.Clear()
+            }
+            last = m
+            due = now
+        }
+
+        // Add and Remove wake the scheduler early. Unless that changed
+        // the message showing, its step is left to run its time.
+        //
+        var wait <-chan time.Time
+        if m != nil {
+            if !now.Before(due) {
+                due = now.Add(s.show(m, now))
+            }
+            wait = time.After(due.Sub(now))
+        }
+
+        select {
+        case <-s.stop:
+            return
+        case <-s.wake:
+        case <-wait:
+        }
+    }
+}
@@ -0,0 +1,249 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// A four character TextDisplay that records what it is told to show,
+// with "" for Clear.
+//
+type fakeTextDisplay struct {
+    mutex sync.Mutex
+    shown []string
+}
+
+func (d *fakeTextDisplay) Width() int { return 4 }
+func (d *fakeTextDisplay) Scroll(text string) { d.Write(text) }
+func (d *fakeTextDisplay) Clear() { d.Write("") }
+
+func (d *fakeTextDisplay) Write(text string) {
+    d.mutex.Lock()
+    defer d.mutex.Unlock()
+
+    d.shown = append(d.shown, text)
+}
+
+func (d *fakeTextDisplay) Shown() []string {
+    d.mutex.Lock()
+    defer d.mutex.Unlock()
+
+    return append([]string{}, d.shown...)
+}
+
+func (d *fakeTextDisplay) Last() string {
+    shown := d.Shown()
+    if len(shown) == 0 {
+        return ""
+    }
+    return shown[len(shown) - 1]
+}
+
+// Polls until condition holds, failing the test after a few seconds.
+//
+func waitUntil(t *testing.T, what string, condition func() bool) {
+    deadline := time.Now().Add(5 * time.Second)
+    for !condition() {
+        if time.Now().After(deadline) {
+            t.Fatalf("timed out waiting for %s", what)
+        }
+        time.Sleep(time.Millisecond)
+    }
+}
+
+func startScheduler(scrollDelay time.Duration) (*MessageScheduler, *fakeTextDisplay) {
+    display := &fakeTextDisplay{}
+    scheduler := NewMessageScheduler(display)
+    scheduler.SetScrollDelay(scrollDelay)
+    scheduler.Start()
+    return scheduler, display
+}
+
+// The windows a scroll of text shows on the fake display.
+//
+func scrollWindows(text string) []string {
+    padded := "    " + text + "    "
+    var windows []string
+    for i := 0 ; i + 4 <= len(padded) ; i++ {
+        windows = append(windows, padded[i:i + 4])
+    }
+    return windows
+}
+
+func count(shown []string, text string) int {
+    n := 0
+    for _, s := range shown {
+        if s == text { n++ }
+    }
+    return n
+}
+
+func TestSchedulerAddDoesNotAdvanceScroll(t *testing.T) {
+    scheduler, display := startScheduler(time.Second)
+    defer scheduler.Stop()
+
+    scheduler.Add(Message { Text: "ABCDEFGH", Scroll: true, Repeat: REPEAT_FOREVER })
+    waitUntil(t, "the scroll", func() bool { return len(display.Shown()) > 0 })
+
+    for i := 0 ; i < 5 ; i++ {
+        scheduler.Remove(scheduler.Add(Message { Text: "LOW", Priority: -1 }))
+    }
+    time.Sleep(50 * time.Millisecond)
+
+    if shown := display.Shown() ; len(shown) != 1 {
+        t.Errorf("adding and removing messages moved the scroll on: %q", shown)
+    }
+}
+
+func TestSchedulerPreempts(t *testing.T) {
+    scheduler, display := startScheduler(10 * time.Millisecond)
+    defer scheduler.Stop()
+
+    scheduler.Add(Message { Text: "ABCDEFGH", Scroll: true, Repeat: REPEAT_FOREVER })
+    waitUntil(t, "the scroll", func() bool { return len(display.Shown()) > 2 })
+
+    scheduler.Add(Message { Text: "HIGH", Priority: 1, MinimumTime: time.Hour })
+    waitUntil(t, "HIGH", func() bool { return display.Last() == "HIGH" })
+
+    if current, ok := scheduler.Current() ; !ok || current.Text != "HIGH" {
+        t.Errorf("current is %q, not HIGH", current.Text)
+    }
+    if messages := scheduler.Messages() ; len(messages) != 2 || messages[0].Text != "HIGH" {
+        t.Errorf("messages are %+v", messages)
+    }
+}
+
+func TestSchedulerMinimumTime(t *testing.T) {
+    scheduler, display := startScheduler(10 * time.Millisecond)
+    defer scheduler.Stop()
+
+    scheduler.Add(Message { Text: "LOW", MinimumTime: 200 * time.Millisecond })
+    waitUntil(t, "LOW", func() bool { return display.Last() == "LOW" })
+    added := time.Now()
+
+    scheduler.Add(Message { Text: "HIGH", Priority: 1 })
+    waitUntil(t, "HIGH", func() bool { return display.Last() == "HIGH" })
+
+    if waited := time.Since(added) ; waited < 150 * time.Millisecond {
+        t.Errorf("HIGH interrupted LOW after %s, inside its minimum time", waited)
+    }
+    if n := count(display.Shown(), "LOW") ; n != 1 {
+        t.Errorf("LOW was written %d times", n)
+    }
+}
+
+func TestSchedulerResumesScroll(t *testing.T) {
+    scheduler, display := startScheduler(20 * time.Millisecond)
+    defer scheduler.Stop()
+
+    windows := scrollWindows("ABCDEFGH")
+    scheduler.Add(Message { Text: "ABCDEFGH", Scroll: true })
+    waitUntil(t, "the scroll to reach C", func() bool { return display.Last() == windows[6] })
+
+    scheduler.Add(Message { Text: "HI", Priority: 1, MinimumTime: 50 * time.Millisecond })
+    waitUntil(t, "the scroll to end", func() bool { return display.Last() == "" })
+
+    // The scroll goes on from the window after the last it showed, and
+    // shows every window once.
+    //
+    shown := display.Shown()
+    at := 0
+    for shown[at] != "HI" {
+        at++
+    }
+    resumed := shown[at + 1]
+    if !strings.Contains(strings.Join(windows, "|"), shown[at - 1] + "|" + resumed) {
+        t.Errorf("the scroll stopped at %q and resumed at %q", shown[at - 1], resumed)
+    }
+    if n := count(shown, "HI") ; n != 1 {
+        t.Errorf("HI was written %d times", n)
+    }
+    for _, window := range windows[1:len(windows) - 1] {
+        if n := count(shown, window) ; n != 1 {
+            t.Errorf("window %q was shown %d times in %q", window, n, shown)
+        }
+    }
+}
+
+func TestSchedulerRepeats(t *testing.T) {
+    scheduler, display := startScheduler(5 * time.Millisecond)
+    defer scheduler.Stop()
+
+    scheduler.Add(Message { Text: "ONE", MinimumTime: 10 * time.Millisecond, Repeat: 2 })
+    scheduler.Add(Message { Text: "AB", Scroll: true, Repeat: 1 })
+    waitUntil(t, "both to finish", func() bool {
+        return len(scheduler.Messages()) == 0 && display.Last() == ""
+    })
+
+    shown := display.Shown()
+    if n := count(shown, "ONE") ; n != 3 {
+        t.Errorf("ONE was shown %d times, not 3", n)
+    }
+    if n := count(shown, "  AB") ; n != 2 {
+        t.Errorf("AB scrolled %d times, not 2", n)
+    }
+}
+
+func TestSchedulerExpires(t *testing.T) {
+    scheduler, display := startScheduler(5 * time.Millisecond)
+    defer scheduler.Stop()
+
+    scheduler.Add(Message { Text: "GONE", Priority: 1, Expires: time.Now().Add(-time.Second) })
+    scheduler.Add(Message { Text: "SOON", MinimumTime: 10 * time.Millisecond, Repeat: REPEAT_FOREVER,
+        Expires: time.Now().Add(100 * time.Millisecond) })
+
+    waitUntil(t, "SOON to expire", func() bool {
+        return len(scheduler.Messages()) == 0 && display.Last() == ""
+    })
+    if n := count(display.Shown(), "GONE") ; n != 0 {
+        t.Errorf("an expired message was shown %d times", n)
+    }
+    if n := count(display.Shown(), "SOON") ; n < 2 {
+        t.Errorf("SOON was shown %d times before it expired", n)
+    }
+}
+
+func TestSchedulerTakesTurns(t *testing.T) {
+    scheduler, display := startScheduler(2 * time.Millisecond)
+    defer scheduler.Stop()
+
+    // Messages of equal priority take turns, a pass each, so one that
+    // repeats forever doesn't keep the display to itself.
+    //
+    scheduler.Add(Message { Text: "AB", Scroll: true, Repeat: REPEAT_FOREVER })
+    scheduler.Add(Message { Text: "CD", Scroll: true, Repeat: REPEAT_FOREVER })
+    waitUntil(t, "three turns each", func() bool {
+        shown := display.Shown()
+        return count(shown, "  AB") >= 3 && count(shown, "  CD") >= 3
+    })
+
+    var turns []string
+    for _, s := range display.Shown() {
+        if s == "  AB" || s == "  CD" {
+            turns = append(turns, s)
+        }
+    }
+    for i := range turns {
+        if want := []string{ "  AB", "  CD" }[i % 2] ; turns[i] != want {
+            t.Fatalf("the messages didn't take turns: %q", turns)
+        }
+    }
+}
@@ -0,0 +1,55 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+// The MCP23017 family differs in two ways: how many ports a chip has,
+// and how its registers are reached. MCP23017Driver handles the first
+// itself, and leaves the second to a RegisterConnection.
+//
+
+// A connection that reads and writes a chip's registers one byte at a
+// time. Every i2c.Connection is one, as are SimulatedConnection and
+// MCP23S17Connection.
+//
+type RegisterConnection interface {
+    ReadByteData(reg uint8) (uint8, error)
+    WriteByteData(reg uint8, val uint8) error
+    Close() error
+}
+
+// MCP23008_DEFAULT_ADDRESS is the lowest I2C address an MCP23008
+// can be strapped to. A0-A2 select addresses up to 0x27.
+//
+const MCP23008_DEFAULT_ADDRESS int = 0x20
+
+// MCP23008_PINS is the number of GPIO pins, GP0-GP7, which the driver
+// treats as port A.
+//
+const MCP23008_PINS int = 8
+
+// A driver for the MCP23008 8-bit I2C port expander. It has the
+// MCP23017's registers for port A only, so every MCP23017Driver
+// function works on pins 0-7 and port A. WriteMasked and Output use
+// the low byte.
+//
+func NewMCP23008Driver(addr int) *MCP23017Driver {
+    driver := NewMCP23017Driver(addr)
+    driver.name = "MCP23008"
+    driver.ports = 1
+
+    return driver
+}
@@ -0,0 +1,93 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "testing"
+)
+
+// The MCP23008's registers are the MCP23017's port A registers at half
+// the address.
+//
+const (
+    mcp23008_IODIR = 0x00
+    mcp23008_IPOL = 0x01
+    mcp23008_IOCON = 0x05
+    mcp23008_GPPU = 0x06
+    mcp23008_GPIO = 0x09
+    mcp23008_OLAT = 0x0A
+)
+
+func TestMCP23008RegisterMap(t *testing.T) {
+    sim := NewSimulatedConnection()
+    sim.Registers[mcp23008_IODIR] = 0xFF
+    sim.Registers[mcp23008_OLAT] = 0x80
+
+    driver := NewMCP23008Driver(MCP23008_DEFAULT_ADDRESS)
+    if err := driver.StartWithConnection(sim) ; err != nil {
+        t.Fatal(err)
+    }
+    if driver.Pins() != MCP23008_PINS {
+        t.Errorf("an MCP23008 has %d pins, not %d", driver.Pins(), MCP23008_PINS)
+    }
+    if out := driver.Output() ; out != 0x80 {
+        t.Errorf("the cache was loaded as 0x%02x, not 0x80 from OLAT", out)
+    }
+
+    driver.PinMode(0, PIN_OUTPUT)
+    driver.PinMode(6, PIN_INPUT_PULLUP)
+    driver.DigitalWrite(0, true)
+    driver.SetInputPolarity(5, true)
+    driver.SetIOCON(MCP23017_IOCON_ODR)
+
+    for _, reg := range []struct {
+        name string
+        reg byte
+        want byte
+    } {
+        { "IODIR", mcp23008_IODIR, 0xFE },
+        { "IPOL", mcp23008_IPOL, 0x20 },
+        { "IOCON", mcp23008_IOCON, MCP23017_IOCON_ODR },
+        { "GPPU", mcp23008_GPPU, 0x40 },
+        { "OLAT", mcp23008_OLAT, 0x81 },
+    } {
+        if got := sim.Register(reg.reg) ; got != reg.want {
+            t.Errorf("%s is %02x, not %02x", reg.name, got, reg.want)
+        }
+    }
+
+    sim.SetRegister(mcp23008_GPIO, 0x40)
+    if high, err := driver.DigitalRead(6) ; err != nil || !high {
+        t.Errorf("pin 6 reads %v, %v, not high from GPIO", high, err)
+    }
+
+    if err := driver.WriteMasked(0x0F, 0x03) ; err != nil {
+        t.Fatal(err)
+    }
+    if olat := sim.Register(mcp23008_OLAT) ; olat != 0x83 {
+        t.Errorf("OLAT is %02x after WriteMasked, not 83", olat)
+    }
+
+    // Port B and pins past 7 are not there.
+    //
+    if err := driver.PinMode(8, PIN_OUTPUT) ; err == nil {
+        t.Error("pin 8 was accepted")
+    }
+    if err := driver.WritePort(PORT_B, 0xFF) ; err == nil {
+        t.Error("port B was written")
+    }
+}
@@ -0,0 +1,184 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "sync"
+)
+
+// A register level stand-in for an I2C device, for running drivers
+// without any hardware attached.
+//
+// Registers holds the device's 256 register bytes. Block and word
+// writes fill consecutive registers, low byte first as SMBus does.
+// Single byte writes with no register, such as HT16K33 commands, are
+// appended to Commands.
+//
+// OnRead and OnWrite let a simulation behave like a particular chip.
+// OnRead is called before every register read and may return a value
+// to use instead of the stored one. OnWrite is called after every
+// register write. Both are called with the connection locked, and
+// must use Registers directly rather than the connection's methods.
+//
+type SimulatedConnection struct {
+    Registers [256]byte
+    Commands []byte
+    OnRead func(reg uint8) (val uint8, override bool)
+    OnWrite func(reg uint8, val uint8)
+
+    mutex sync.Mutex
+    pointer uint8
+    closed bool
+}
+
+func NewSimulatedConnection() *SimulatedConnection {
+    return &SimulatedConnection{}
+}
+
+func (c *SimulatedConnection) Closed() bool {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    return c.closed
+}
+
+func (c *SimulatedConnection) read(reg uint8) uint8 {
+    if c.OnRead != nil {
+        if val, override := c.OnRead(reg) ; override {
+            return val
+        }
+    }
+    return c.Registers[reg]
+}
+
+func (c *SimulatedConnection) write(reg uint8, val uint8) {
+    c.Registers[reg] = val
+    if c.OnWrite != nil {
+        c.OnWrite(reg, val)
+    }
+}
+
+// Register returns the stored value of a register, for inspecting
+// what a driver has written.
+//
+func (c *SimulatedConnection) Register(reg uint8) uint8 {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    return c.Registers[reg]
+}
+
+// SetRegister changes the stored value of a register, for simulating
+// the device changing it.
+//
+func (c *SimulatedConnection) SetRegister(reg uint8, val uint8) {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    c.Registers[reg] = val
+}
+
+// Read reads consecutive registers from the last register written.
+//
+func (c *SimulatedConnection) Read(data []byte) (int, error) {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+
+    for i := range data {
+        data[i] = c.read(c.pointer)
+        c.pointer++
+    }
+    return len(data), nil
+}
+
+// Write treats the first byte as a register address and writes any
+// remaining bytes to consecutive registers from there.
+//
+func (c *SimulatedConnection) Write(data []byte) (int, error) {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+
+    if len(data) == 0 {
+        return 0, nil
+    }
+
+    c.pointer = data[0]
+    for _, val := range data[1:] {
+        c.write(c.pointer, val)
+        c.pointer++
+    }
+    return len(data), nil
+}
+
+func (c *SimulatedConnection) Close() error {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    c.closed = true
+    return nil
+}
+
+func (c *SimulatedConnection) ReadByte() (byte, error) {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+
+    val := c.read(c.pointer)
+    c.pointer++
+    return val, nil
+}
+
+func (c *SimulatedConnection) ReadByteData(reg uint8) (uint8, error) {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    return c.read(reg), nil
+}
+
+func (c *SimulatedConnection) ReadWordData(reg uint8) (uint16, error) {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    return uint16(c.read(reg)) | uint16(c.read(reg + 1)) << 8, nil
+}
+
+func (c *SimulatedConnection) WriteByte(val byte) error {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+
+    c.Commands = append(c.Commands, val)
+    c.pointer = val
+    return nil
+}
+
+func (c *SimulatedConnection) WriteByteData(reg uint8, val uint8) error {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    c.write(reg, val)
+    return nil
+}
+
+func (c *SimulatedConnection) WriteWordData(reg uint8, val uint16) error {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    c.write(reg, uint8(val))
+    c.write(reg + 1, uint8(val >> 8))
+    return nil
+}
+
+func (c *SimulatedConnection) WriteBlockData(reg uint8, data []byte) error {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+
+    for i, val := range data {
+        c.write(reg + uint8(i), val)
+    }
+    return nil
+}
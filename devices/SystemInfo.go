@@ -0,0 +1,287 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "bufio"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// Where ReadSystemInfo finds each fact. DefaultSystemInfoPaths are the
+// real files; SystemInfoPathsUnder puts the same files under another
+// root, so that a fixture tree copied from a board can be read anywhere.
+//
+// Throttled is the Raspberry Pi firmware's get_throttled, the same flags
+// as vcgencmd get_throttled. Temperature, Throttled and Model are
+// missing on some boards, which isn't an error.
+//
+type SystemInfoPaths struct {
+    Model string
+    CPUInfo string
+    MemInfo string
+    Temperature string
+    LoadAverage string
+    Throttled string
+    KernelRelease string
+}
+
+func DefaultSystemInfoPaths() SystemInfoPaths {
+    return SystemInfoPaths {
+        Model: "/proc/device-tree/model",
+        CPUInfo: "/proc/cpuinfo",
+        MemInfo: "/proc/meminfo",
+        Temperature: "/sys/class/thermal/thermal_zone0/temp",
+        LoadAverage: "/proc/loadavg",
+        Throttled: "/sys/devices/platform/soc/soc:firmware/get_throttled",
+        KernelRelease: "/proc/sys/kernel/osrelease",
+    }
+}
+
+func SystemInfoPathsUnder(root string) SystemInfoPaths {
+    paths := DefaultSystemInfoPaths()
+    for _, path := range []*string{&paths.Model, &paths.CPUInfo, &paths.MemInfo, &paths.Temperature,
+        &paths.LoadAverage, &paths.Throttled, &paths.KernelRelease} {
+        *path = filepath.Join(root, *path)
+    }
+    return paths
+}
+
+// The flags in get_throttled. The low bits are set while the condition
+// holds, and the matching bits from 16 up once it has happened since
+// boot.
+//
+type ThrottleState uint32
+
+const (
+    THROTTLE_UNDER_VOLTAGE ThrottleState = 0x1
+    THROTTLE_FREQUENCY_CAPPED ThrottleState = 0x2
+    THROTTLE_THROTTLED ThrottleState = 0x4
+    THROTTLE_SOFT_TEMP_LIMIT ThrottleState = 0x8
+    THROTTLE_OCCURRED_SHIFT uint = 16
+)
+
+var throttleNames = []struct {
+    flag ThrottleState
+    name string
+} {
+    { THROTTLE_UNDER_VOLTAGE, "under-voltage" },
+    { THROTTLE_FREQUENCY_CAPPED, "frequency capped" },
+    { THROTTLE_THROTTLED, "throttled" },
+    { THROTTLE_SOFT_TEMP_LIMIT, "soft temperature limit" },
+}
+
+// Whether a condition holds now.
+//
+func (t ThrottleState) Now(flag ThrottleState) bool { return t & flag != 0 }
+
+// Whether a condition has happened since boot.
+//
+func (t ThrottleState) Occurred(flag ThrottleState) bool { return t & (flag << THROTTLE_OCCURRED_SHIFT) != 0 }
+
+// Lists the conditions that hold now, then those that have happened,
+// or returns OK.
+//
+func (t ThrottleState) String() string {
+    var now, occurred []string
+    for _, n := range throttleNames {
+        if t.Now(n.flag) {
+            now = append(now, n.name)
+        } else if t.Occurred(n.flag) {
+            occurred = append(occurred, n.name)
+        }
+    }
+
+    var parts []string
+    if len(now) > 0 {
+        parts = append(parts, strings.Join(now, ", "))
+    }
+    if len(occurred) > 0 {
+        parts = append(parts, "earlier " + strings.Join(occurred, ", "))
+    }
+    if len(parts) == 0 {
+        return "OK"
+    }
+    return strings.Join(parts, "; ")
+}
+
+// The facts rpi sysinfo shows, as shell/rpinfo.sh printed them, along
+// with the temperature, load and throttling, which change.
+//
+// Memory is in bytes and Temperature in degrees Celsius. HasTemperature
+// and HasThrottled are false where the board doesn't report them.
+//
+type SystemInfo struct {
+    Model string
+    CPU string
+    Cores int
+    Hardware string
+    Revision string
+    Serial string
+    MemTotal uint64
+    MemAvailable uint64
+    Kernel string
+    Temperature float64
+    HasTemperature bool
+    Load [3]float64
+    Throttled ThrottleState
+    HasThrottled bool
+}
+
+// The ARM parts in /proc/cpuinfo's "CPU part", for the boards that
+// don't give a model name.
+//
+var armCPUParts = map[string]string {
+    "0xb76": "ARM1176",
+    "0xc07": "Cortex-A7",
+    "0xd03": "Cortex-A53",
+    "0xd07": "Cortex-A57",
+    "0xd08": "Cortex-A72",
+    "0xd0b": "Cortex-A76",
+}
+
+// Reads every fact it can. Only a missing cpuinfo, meminfo or load
+// average is an error, as every Linux system has them.
+//
+func ReadSystemInfo(paths SystemInfoPaths) (info SystemInfo, err error) {
+    info.Model = readFact(paths.Model)
+    info.Kernel = readFact(paths.KernelRelease)
+
+    if err = info.readCPUInfo(paths.CPUInfo) ; err != nil {
+        return info, err
+    }
+    if err = info.readMemInfo(paths.MemInfo) ; err != nil {
+        return info, err
+    }
+    if err = info.Refresh(paths) ; err != nil {
+        return info, err
+    }
+    return info, nil
+}
+
+// Reads a single line file, dropping the trailing NUL the device tree
+// leaves, or returns "" if there is no such file.
+//
+func readFact(path string) string {
+    text, err := ioutil.ReadFile(path)
+    if err != nil {
+        return ""
+    }
+    return strings.TrimSpace(strings.TrimRight(string(text), "\x00"))
+}
+
+// Reads "name : value" lines, calling found for each one.
+//
+func readFields(path string, found func(name, value string)) error {
+    file, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        fields := strings.SplitN(scanner.Text(), ":", 2)
+        if len(fields) == 2 {
+            found(strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1]))
+        }
+    }
+    return scanner.Err()
+}
+
+func (info *SystemInfo) readCPUInfo(path string) error {
+    var part string
+
+    err := readFields(path, func(name, value string) {
+        switch name {
+        case "processor":
+            info.Cores++
+        case "model name":
+            if len(info.CPU) == 0 { info.CPU = value }
+        case "CPU part":
+            part = value
+        case "Hardware":
+            info.Hardware = value
+        case "Revision":
+            info.Revision = value
+        case "Serial":
+            info.Serial = value
+        case "Model":
+            if len(info.Model) == 0 { info.Model = value }
+        }
+    })
+
+    // ARM boards call every core "ARMv7 Processor rev 4 (v7l)", or
+    // leave model name out, so the part number says more.
+    //
+    if name, ok := armCPUParts[part] ; ok {
+        info.CPU = name
+    }
+    return err
+}
+
+func (info *SystemInfo) readMemInfo(path string) error {
+    return readFields(path, func(name, value string) {
+        kilobytes, err := strconv.ParseUint(strings.TrimSuffix(value, " kB"), 10, 64)
+        if err != nil {
+            return
+        }
+
+        switch name {
+        case "MemTotal":
+            info.MemTotal = kilobytes * 1024
+        case "MemAvailable":
+            info.MemAvailable = kilobytes * 1024
+        }
+    })
+}
+
+// Reads again the facts that change: the load average, temperature,
+// throttling and available memory.
+//
+func (info *SystemInfo) Refresh(paths SystemInfoPaths) error {
+    fields := strings.Fields(readFact(paths.LoadAverage))
+    if len(fields) < 3 {
+        return fmt.Errorf(" Can't read the load average from %s", paths.LoadAverage)
+    }
+    for i := range info.Load {
+        load, err := strconv.ParseFloat(fields[i], 64)
+        if err != nil {
+            return err
+        }
+        info.Load[i] = load
+    }
+
+    if millidegrees, err := strconv.Atoi(readFact(paths.Temperature)) ; err == nil {
+        info.Temperature = float64(millidegrees) / 1000
+        info.HasTemperature = true
+    }
+
+    // The firmware reports the flags in hex, as 0x50005 or just 0.
+    //
+    throttled := readFact(paths.Throttled)
+    if flags, err := strconv.ParseUint(strings.TrimPrefix(throttled, "0x"), 16, 32) ; err == nil {
+        info.Throttled = ThrottleState(flags)
+        info.HasThrottled = true
+    }
+
+    return info.readMemInfo(paths.MemInfo)
+}
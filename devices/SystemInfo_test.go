@@ -0,0 +1,120 @@
+/*
+Copyright (c) 2020 William H. Beebe, Jr.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devices
+
+import (
+    "path/filepath"
+    "testing"
+)
+
+// The fixture trees in testdata hold the files ReadSystemInfo reads, as
+// found on a Raspberry Pi 4 and on a PC with no thermal zone or
+// get_throttled. get_throttled sits at the top of the Pi 4 tree, as the
+// colon in its real directory, soc:firmware, is no good in a module.
+//
+func readFixture(t *testing.T, board string) SystemInfo {
+    root := filepath.Join("testdata", board)
+    paths := SystemInfoPathsUnder(root)
+    paths.Throttled = filepath.Join(root, "get_throttled")
+
+    info, err := ReadSystemInfo(paths)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return info
+}
+
+func TestSystemInfoPi4(t *testing.T) {
+    info := readFixture(t, "pi4")
+
+    for _, fact := range []struct {
+        name string
+        got string
+        want string
+    } {
+        { "model", info.Model, "Raspberry Pi 4 Model B Rev 1.4" },
+        { "CPU", info.CPU, "Cortex-A72" },
+        { "hardware", info.Hardware, "BCM2711" },
+        { "revision", info.Revision, "d03114" },
+        { "serial", info.Serial, "10000000a1b2c3d4" },
+        { "kernel", info.Kernel, "5.10.17-v7l+" },
+        { "throttling", info.Throttled.String(), "frequency capped; earlier under-voltage, throttled" },
+    } {
+        if fact.got != fact.want {
+            t.Errorf("%s is %q, not %q", fact.name, fact.got, fact.want)
+        }
+    }
+
+    if info.Cores != 4 {
+        t.Errorf("%d cores, not 4", info.Cores)
+    }
+    if info.MemTotal != 7999784 * 1024 || info.MemAvailable != 7551212 * 1024 {
+        t.Errorf("memory is %d with %d available", info.MemTotal, info.MemAvailable)
+    }
+    if !info.HasTemperature || !closeTo(info.Temperature, 48.686) {
+        t.Errorf("temperature is %v, %v, not 48.686", info.Temperature, info.HasTemperature)
+    }
+    if info.Load != [3]float64 { 0.52, 0.38, 0.30 } {
+        t.Errorf("load is %v", info.Load)
+    }
+    if !info.HasThrottled || info.Throttled != 0x50002 {
+        t.Errorf("throttled is 0x%x, %v, not 0x50002", uint32(info.Throttled), info.HasThrottled)
+    }
+    if !info.Throttled.Now(THROTTLE_FREQUENCY_CAPPED) || info.Throttled.Now(THROTTLE_UNDER_VOLTAGE) ||
+        !info.Throttled.Occurred(THROTTLE_UNDER_VOLTAGE) || info.Throttled.Occurred(THROTTLE_SOFT_TEMP_LIMIT) {
+        t.Errorf("throttled flags are wrong in 0x%x", uint32(info.Throttled))
+    }
+}
+
+func TestSystemInfoWithoutSensors(t *testing.T) {
+    info := readFixture(t, "pc")
+
+    if info.Model != "" || info.Hardware != "" {
+        t.Errorf("model %q and hardware %q on a PC", info.Model, info.Hardware)
+    }
+    if info.CPU != "Intel(R) Core(TM) i5-8250U CPU @ 1.60GHz" || info.Cores != 2 {
+        t.Errorf("CPU is %d of %q", info.Cores, info.CPU)
+    }
+    if info.MemTotal != 2035264 * 1024 || info.MemAvailable != 1502816 * 1024 {
+        t.Errorf("memory is %d with %d available", info.MemTotal, info.MemAvailable)
+    }
+    if info.HasTemperature || info.HasThrottled {
+        t.Errorf("temperature %v and throttling %v found on a PC", info.HasTemperature, info.HasThrottled)
+    }
+    if info.Load != [3]float64 { 1.05, 0.75, 0.50 } {
+        t.Errorf("load is %v", info.Load)
+    }
+}
+
+func TestSystemInfoMissing(t *testing.T) {
+    if _, err := ReadSystemInfo(SystemInfoPathsUnder(filepath.Join("testdata", "none"))) ; err == nil {
+        t.Error("read a system with no cpuinfo")
+    }
+}
+
+func TestThrottleStateString(t *testing.T) {
+    for state, want := range map[ThrottleState]string {
+        0: "OK",
+        0x50005: "under-voltage, throttled",
+        0x80000: "earlier soft temperature limit",
+        0x30008: "soft temperature limit; earlier under-voltage, frequency capped",
+    } {
+        if got := state.String() ; got != want {
+            t.Errorf("0x%x is %q, not %q", uint32(state), got, want)
+        }
+    }
+}